@@ -1,5 +1,13 @@
 package godingtalk
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
 const (
 	EVENT_USER_ADD_ORG = "user_add_org"  // 通讯录用户增加
 	EVENT_USER_MODIFY_ORG = "user_modify_org"  // 通讯录用户更改
@@ -15,6 +23,17 @@ const (
 	EVENT_LABEL_CONF_ADD = "label_conf_add"  // 增加角色或者角色组
 	EVENT_LABEL_CONF_DEL = "label_conf_del"  // 删除角色或者角色组
 	EVENT_LABEL_CONF_MODIFY = "label_conf_modify"  // 修改角色或者角色组
+	EVENT_CHECK_URL = "check_url"  // 注册或更新回调时验证回调地址
+	EVENT_BPMS_TASK_CHANGE = "bpms_task_change"  // 审批任务开始，结束，转交
+	EVENT_BPMS_INSTANCE_CHANGE = "bpms_instance_change"  // 审批实例开始，结束
+	EVENT_ATTENDANCE_CHECK_RECORD = "attendance_check_record"  // 员工打卡
+	EVENT_CHECK_IN = "check_in"  // 用户签到
+	EVENT_CHAT_ADD_MEMBER = "chat_add_member"  // 群会话添加人员
+	EVENT_CHAT_REMOVE_MEMBER = "chat_remove_member"  // 群会话删除人员
+	EVENT_CHAT_QUIT = "chat_quit"  // 群会话用户主动退群
+	EVENT_CHAT_UPDATE_OWNER = "chat_update_owner"  // 群会话更换群主
+	EVENT_CHAT_UPDATE_TITLE = "chat_update_title"  // 群会话更换群名称
+	EVENT_CHAT_DISBAND = "chat_disband"  // 群会话解散群
 )
 
 type ContactEvent struct {
@@ -35,6 +54,11 @@ type Callback struct {
 
 //RegisterCallback is 注册事件回调接口
 func (c *DingTalkClient) RegisterCallback(callbacks []string, token string, aes_key string, callbackURL string) error {
+	return c.RegisterCallbackContext(context.Background(), callbacks, token, aes_key, callbackURL)
+}
+
+//RegisterCallbackContext is RegisterCallback with a context
+func (c *DingTalkClient) RegisterCallbackContext(ctx context.Context, callbacks []string, token string, aes_key string, callbackURL string) error {
 	var data OAPIResponse
 	request := map[string]interface{}{
 		"call_back_tag": callbacks,
@@ -42,12 +66,17 @@ func (c *DingTalkClient) RegisterCallback(callbacks []string, token string, aes_
 		"aes_key":       aes_key,
 		"url":           callbackURL,
 	}
-	err := c.httpRPC("call_back/register_call_back", nil, request, &data)
+	err := c.httpRPC(ctx, "call_back/register_call_back", nil, request, &data)
 	return err
 }
 
 //UpdateCallback is 更新事件回调接口
 func (c *DingTalkClient) UpdateCallback(callbacks []string, token string, aes_key string, callbackURL string) error {
+	return c.UpdateCallbackContext(context.Background(), callbacks, token, aes_key, callbackURL)
+}
+
+//UpdateCallbackContext is UpdateCallback with a context
+func (c *DingTalkClient) UpdateCallbackContext(ctx context.Context, callbacks []string, token string, aes_key string, callbackURL string) error {
 	var data OAPIResponse
 	request := map[string]interface{}{
 		"call_back_tag": callbacks,
@@ -55,20 +84,117 @@ func (c *DingTalkClient) UpdateCallback(callbacks []string, token string, aes_ke
 		"aes_key":       aes_key,
 		"url":           callbackURL,
 	}
-	err := c.httpRPC("call_back/update_call_back", nil, request, &data)
+	err := c.httpRPC(ctx, "call_back/update_call_back", nil, request, &data)
 	return err
 }
 
 //DeleteCallback is 删除事件回调接口
 func (c *DingTalkClient) DeleteCallback() error {
+	return c.DeleteCallbackContext(context.Background())
+}
+
+//DeleteCallbackContext is DeleteCallback with a context
+func (c *DingTalkClient) DeleteCallbackContext(ctx context.Context) error {
 	var data OAPIResponse
-	err := c.httpRPC("call_back/delete_call_back", nil, nil, &data)
+	err := c.httpRPC(ctx, "call_back/delete_call_back", nil, nil, &data)
 	return err
 }
 
 //ListCallback is 查询事件回调接口
 func (c *DingTalkClient) ListCallback() (Callback, error) {
+	return c.ListCallbackContext(context.Background())
+}
+
+//ListCallbackContext is ListCallback with a context
+func (c *DingTalkClient) ListCallbackContext(ctx context.Context) (Callback, error) {
 	var data Callback
-	err := c.httpRPC("call_back/get_call_back", nil, nil, &data)
+	err := c.httpRPC(ctx, "call_back/get_call_back", nil, nil, &data)
 	return data, err
 }
+
+//GetFailedCallbacks is 获取回调失败的结果. It returns up to 200 events which DingTalk
+//could not push, converted to the shape a CallbackHandler receives, and whether
+//more are left. Events are only returned once, so when some items cannot be
+//converted the others are still returned, along with an error naming the bad items
+func (c *DingTalkClient) GetFailedCallbacks() ([]*CallbackEvent, bool, error) {
+	return c.GetFailedCallbacksContext(context.Background())
+}
+
+//GetFailedCallbacksContext is GetFailedCallbacks with a context
+func (c *DingTalkClient) GetFailedCallbacksContext(ctx context.Context) ([]*CallbackEvent, bool, error) {
+	var data struct {
+		OAPIResponse
+		HasMore    bool                         `json:"has_more"`
+		FailedList []map[string]json.RawMessage `json:"failed_list"`
+	}
+	err := c.httpRPC(ctx, "call_back/get_call_back_failed_result", nil, nil, &data)
+	if err != nil {
+		return nil, false, err
+	}
+	events := make([]*CallbackEvent, 0, len(data.FailedList))
+	var errs []error
+	for i, item := range data.FailedList {
+		event, err := failedCallbackEvent(item)
+		if err != nil {
+			raw, _ := json.Marshal(item)
+			errs = append(errs, fmt.Errorf("failed callback %d %s: %w", i, raw, err))
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, data.HasMore, errors.Join(errs...)
+}
+
+//failedCallbackFields maps the fields of get_call_back_failed_result to those of pushed events
+var failedCallbackFields = map[string]string{
+	"event_time": "TimeStamp",
+	"corpid":     "CorpId",
+	"userid":     "UserId",
+	"deptid":     "DeptId",
+	"chatid":     "ChatId",
+}
+
+//failedCallbackEvent converts an item of get_call_back_failed_result, which holds the
+//event either in an object named after its tag or in its own fields
+func failedCallbackEvent(item map[string]json.RawMessage) (*CallbackEvent, error) {
+	var tag string
+	if err := json.Unmarshal(item["call_back_tag"], &tag); err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		return nil, errors.New("call_back_tag is missing")
+	}
+	fields := map[string]json.RawMessage{}
+	if nested, ok := item[tag]; ok && len(nested) > 0 && nested[0] == '{' {
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, err
+		}
+	} else {
+		for k, v := range item {
+			if k == "call_back_tag" {
+				continue
+			}
+			if name, ok := failedCallbackFields[k]; ok {
+				k = name
+			}
+			fields[k] = v
+		}
+		// pushed events carry department ids as strings
+		if ids, ok := fields["DeptId"]; ok {
+			var numbers []int64
+			if json.Unmarshal(ids, &numbers) == nil {
+				strs := make([]string, len(numbers))
+				for i, n := range numbers {
+					strs[i] = strconv.FormatInt(n, 10)
+				}
+				fields["DeptId"], _ = json.Marshal(strs)
+			}
+		}
+	}
+	fields["EventType"], _ = json.Marshal(tag)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &CallbackEvent{EventType: tag, Data: data}, nil
+}
@@ -1,6 +1,9 @@
 package godingtalk
 
 import (
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +29,53 @@ func TestListCallback(t *testing.T) {
 	}
 	t.Log(data)
 }
+
+func TestGetFailedCallbacks(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/call_back/get_call_back_failed_result" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		return jsonResponse(200, `{"errcode":0,"has_more":true,"failed_list":[
+			{"event_time":1500000000000,"call_back_tag":"org_dept_create","deptid":[1,2],"corpid":"corp"},
+			{"call_back_tag":"bpms_instance_change","bpms_instance_change":{"processInstanceId":"p1","type":"start"}}]}`), nil
+	})
+	events, hasMore, err := client.GetFailedCallbacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasMore || len(events) != 2 {
+		t.Fatalf("unexpected result %v %v", events, hasMore)
+	}
+
+	var contact ContactEvent
+	if err = events[0].Decode(&contact); err != nil {
+		t.Fatal(err)
+	}
+	expected := ContactEvent{EventType: EVENT_ORG_DEPT_CREATE, TimeStamp: 1500000000000, DeptIDs: []string{"1", "2"}, CorpID: "corp"}
+	if !reflect.DeepEqual(contact, expected) {
+		t.Errorf("got %+v, expected %+v", contact, expected)
+	}
+
+	var approval ApprovalInstanceEvent
+	if err = events[1].Decode(&approval); err != nil {
+		t.Fatal(err)
+	}
+	if events[1].EventType != EVENT_BPMS_INSTANCE_CHANGE || approval.EventType != EVENT_BPMS_INSTANCE_CHANGE || approval.ProcessInstanceID != "p1" {
+		t.Errorf("unexpected approval event %+v", approval)
+	}
+}
+
+func TestGetFailedCallbacksKeepsGoodItems(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(200, `{"errcode":0,"has_more":false,"failed_list":[
+			{"call_back_tag":"user_add_org","userid":["u1"]},
+			{"call_back_tag":42}]}`), nil
+	})
+	events, _, err := client.GetFailedCallbacks()
+	if len(events) != 1 || events[0].EventType != EVENT_USER_ADD_ORG {
+		t.Errorf("expected the valid event, got %v", events)
+	}
+	if err == nil || !strings.Contains(err.Error(), `"call_back_tag":42`) {
+		t.Errorf("expected the bad item in the error, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//InteractiveCard is 互动卡片, created from a card template and sent to a user or a chat
+type InteractiveCard struct {
+	TemplateID       string // 卡片模板ID
+	OutTrackID       string // 卡片的唯一标识, UpdateInteractiveCard 用它来更新卡片
+	ChatID           string // 群会话的 open_conversation_id, 为空时单聊发给 UserID
+	UserID           string
+	RobotCode        string // 群聊时以该机器人的身份发送
+	CallbackRouteKey string // 卡片回调的路由, 见 RegisterCardCallback
+
+	Data        map[string]string            // 卡片的公有数据
+	PrivateData map[string]map[string]string // 每个用户的私有数据, key 为 userid
+}
+
+//CardUpdate is new data for an interactive card. Only the keys given are changed
+type CardUpdate struct {
+	Data        map[string]string
+	PrivateData map[string]map[string]string
+}
+
+func cardParams(data map[string]string) map[string]interface{} {
+	return map[string]interface{}{"card_param_map": data}
+}
+
+func cardPrivateParams(data map[string]map[string]string) map[string]interface{} {
+	private := map[string]interface{}{}
+	for userID, params := range data {
+		private[userID] = cardParams(params)
+	}
+	return private
+}
+
+//SendInteractiveCard is 发送互动卡片
+func (c *DingTalkClient) SendInteractiveCard(card InteractiveCard) error {
+	return c.SendInteractiveCardContext(context.Background(), card)
+}
+
+//SendInteractiveCardContext is SendInteractiveCard with a context
+func (c *DingTalkClient) SendInteractiveCardContext(ctx context.Context, card InteractiveCard) error {
+	if card.TemplateID == "" || card.OutTrackID == "" {
+		return errors.New("interactive card needs a template id and an out track id")
+	}
+	request := map[string]interface{}{
+		"card_template_id": card.TemplateID,
+		"out_track_id":     card.OutTrackID,
+		"card_data":        cardParams(card.Data),
+	}
+	if card.ChatID != "" {
+		request["conversation_type"] = 1
+		request["open_conversation_id"] = card.ChatID
+	} else if card.UserID != "" {
+		receiver, _ := json.Marshal(map[string]string{"userid": card.UserID})
+		request["conversation_type"] = 0
+		request["single_chat_receiver"] = string(receiver)
+	} else {
+		return errors.New("interactive card has no receiver")
+	}
+	if card.RobotCode != "" {
+		request["robot_code"] = card.RobotCode
+	}
+	if card.CallbackRouteKey != "" {
+		request["callback_route_key"] = card.CallbackRouteKey
+	}
+	if len(card.PrivateData) > 0 {
+		request["private_data"] = cardPrivateParams(card.PrivateData)
+	}
+	var data OAPIResponse
+	return c.httpRPC(ctx, "topapi/im/chat/interactivecards/send", nil, request, &data)
+}
+
+//UpdateInteractiveCard is 更新互动卡片, identified by the OutTrackID it was sent with
+func (c *DingTalkClient) UpdateInteractiveCard(outTrackID string, update CardUpdate) error {
+	return c.UpdateInteractiveCardContext(context.Background(), outTrackID, update)
+}
+
+//UpdateInteractiveCardContext is UpdateInteractiveCard with a context
+func (c *DingTalkClient) UpdateInteractiveCardContext(ctx context.Context, outTrackID string, update CardUpdate) error {
+	request := map[string]interface{}{
+		"out_track_id": outTrackID,
+		"card_options": map[string]interface{}{
+			"update_card_data_by_key":    true,
+			"update_private_data_by_key": true,
+		},
+	}
+	if len(update.Data) > 0 {
+		request["card_data"] = cardParams(update.Data)
+	}
+	if len(update.PrivateData) > 0 {
+		request["private_data"] = cardPrivateParams(update.PrivateData)
+	}
+	var data OAPIResponse
+	return c.httpRPC(ctx, "topapi/im/chat/interactivecards/update", nil, request, &data)
+}
+
+//RegisterCardCallback is 注册互动卡片回调地址. Cards sent with routeKey post their
+//actions to callbackURL, signed with secret, which a CardHandler verifies
+func (c *DingTalkClient) RegisterCardCallback(callbackURL string, secret string, routeKey string) error {
+	return c.RegisterCardCallbackContext(context.Background(), callbackURL, secret, routeKey)
+}
+
+//RegisterCardCallbackContext is RegisterCardCallback with a context
+func (c *DingTalkClient) RegisterCardCallbackContext(ctx context.Context, callbackURL string, secret string, routeKey string) error {
+	request := map[string]interface{}{
+		"callback_url":       callbackURL,
+		"api_secret":         secret,
+		"callback_route_key": routeKey,
+		"forceUpdate":        true,
+	}
+	var data OAPIResponse
+	return c.httpRPC(ctx, "topapi/im/chat/scencegroup/interactivecard/callback/register", nil, request, &data)
+}
+
+//CardCallback is an action on an interactive card, such as a button being clicked
+type CardCallback struct {
+	OutTrackID string `json:"outTrackId"`
+	CorpID     string `json:"corpId"`
+	UserID     string `json:"userId"`
+	Content    string `json:"content"` // 原始的回调内容, Action 和 Params 由此解析
+
+	Action string                 `json:"-"`
+	Params map[string]interface{} `json:"-"`
+}
+
+//CardActionFunc handles an action of a card. The returned update, if not nil, is
+//applied to the card: Data for everyone, and the PrivateData of the user who acted.
+//Use UpdateInteractiveCard to change the private data of other users
+type CardActionFunc func(ctx context.Context, cb *CardCallback) (*CardUpdate, error)
+
+//CardHandler is an http.Handler for the callbacks of interactive cards, which calls
+//the function registered for the action of each callback
+type CardHandler struct {
+	Secret       string
+	MaxClockSkew time.Duration // 为0时使用 DefaultRobotMaxClockSkew
+	Logger       Logger
+
+	mu      sync.RWMutex
+	actions map[string]CardActionFunc
+}
+
+//NewCardHandler creates a CardHandler verifying callbacks with the secret given to RegisterCardCallback
+func NewCardHandler(secret string) *CardHandler {
+	return &CardHandler{
+		Secret:  secret,
+		actions: map[string]CardActionFunc{},
+	}
+}
+
+//Handle registers fn for the action with the given id
+func (h *CardHandler) Handle(action string, fn CardActionFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.actions == nil {
+		h.actions = map[string]CardActionFunc{}
+	}
+	h.actions[action] = fn
+}
+
+func (h *CardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := verifyTimestampSign(r.Header.Get("timestamp"), r.Header.Get("sign"), h.Secret, h.MaxClockSkew, time.Now()); err != nil {
+		h.log(ctx, slog.LevelWarn, "dingtalk card callback rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var cb CardCallback
+	if !decodeCallbackBody(w, r, &cb, "invalid card callback") {
+		return
+	}
+	var content struct {
+		CardPrivateData struct {
+			ActionIDs []string               `json:"actionIds"`
+			Params    map[string]interface{} `json:"params"`
+		} `json:"cardPrivateData"`
+	}
+	if err := json.Unmarshal([]byte(cb.Content), &content); err != nil {
+		http.Error(w, "invalid card callback content", http.StatusBadRequest)
+		return
+	}
+	if ids := content.CardPrivateData.ActionIDs; len(ids) > 0 {
+		cb.Action = ids[0]
+	}
+	cb.Params = content.CardPrivateData.Params
+
+	h.mu.RLock()
+	fn := h.actions[cb.Action]
+	h.mu.RUnlock()
+	if fn == nil {
+		h.log(ctx, slog.LevelWarn, "dingtalk card action has no handler", "action", cb.Action, "out_track_id", cb.OutTrackID)
+		writeCardUpdate(w, nil, "")
+		return
+	}
+	update, err := fn(ctx, &cb)
+	if err != nil {
+		h.log(ctx, slog.LevelError, "dingtalk card handler failed", "action", cb.Action, "out_track_id", cb.OutTrackID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeCardUpdate(w, update, cb.UserID)
+}
+
+//writeCardUpdate answers a callback with the new data of the card, and the private
+//data of the user who acted on it
+func writeCardUpdate(w http.ResponseWriter, update *CardUpdate, userID string) {
+	reply := map[string]interface{}{}
+	if update != nil {
+		if len(update.Data) > 0 {
+			reply["cardData"] = map[string]interface{}{"cardParamMap": update.Data}
+		}
+		if private, ok := update.PrivateData[userID]; ok {
+			reply["userPrivateData"] = map[string]interface{}{"cardParamMap": private}
+		}
+	}
+	w.Header().Set("Content-Type", typeJSON+"; charset=UTF-8")
+	json.NewEncoder(w).Encode(reply)
+}
+
+func (h *CardHandler) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Log(ctx, level, msg, args...)
+	}
+}
@@ -0,0 +1,81 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSendInteractiveCard(t *testing.T) {
+	var request map[string]interface{}
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/topapi/im/chat/interactivecards/send" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &request)
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	err := client.SendInteractiveCard(InteractiveCard{
+		TemplateID:       "tpl",
+		OutTrackID:       "track1",
+		UserID:           "u1",
+		CallbackRouteKey: "route",
+		Data:             map[string]string{"status": "pending"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"card_template_id":"tpl","out_track_id":"track1","conversation_type":0,
+		"single_chat_receiver":"{\"userid\":\"u1\"}","callback_route_key":"route",
+		"card_data":{"card_param_map":{"status":"pending"}}}`), &expected)
+	if !reflect.DeepEqual(request, expected) {
+		t.Errorf("got %v, expected %v", request, expected)
+	}
+
+	if err = client.SendInteractiveCard(InteractiveCard{TemplateID: "tpl", OutTrackID: "t"}); err == nil {
+		t.Error("expected an error for a card without receiver")
+	}
+}
+
+func TestCardHandler(t *testing.T) {
+	h := NewCardHandler("secret")
+	h.Handle("approve", func(ctx context.Context, cb *CardCallback) (*CardUpdate, error) {
+		if cb.OutTrackID != "track1" || cb.Params["comment"] != "ok" {
+			t.Errorf("unexpected callback %+v", cb)
+		}
+		return &CardUpdate{
+			Data:        map[string]string{"status": "approved"},
+			PrivateData: map[string]map[string]string{cb.UserID: {"clicked": "true"}},
+		}, nil
+	})
+
+	content, _ := json.Marshal(map[string]interface{}{
+		"cardPrivateData": map[string]interface{}{"actionIds": []string{"approve"}, "params": map[string]string{"comment": "ok"}},
+	})
+	body, _ := json.Marshal(map[string]string{"outTrackId": "track1", "userId": "u1", "content": string(content)})
+	req := signedRobotCallback("secret", time.Now(), string(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+	}
+	var reply, expected map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &reply)
+	json.Unmarshal([]byte(`{"cardData":{"cardParamMap":{"status":"approved"}},"userPrivateData":{"cardParamMap":{"clicked":"true"}}}`), &expected)
+	if !reflect.DeepEqual(reply, expected) {
+		t.Errorf("got %v, expected %v", reply, expected)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRobotCallback("wrong", time.Now(), string(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong sign, got %d", w.Code)
+	}
+}
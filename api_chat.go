@@ -0,0 +1,228 @@
+package godingtalk
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+//Chat is 群会话的信息
+type Chat struct {
+	ChatID              string   `json:"chatid"`
+	Name                string   `json:"name"`
+	Owner               string   `json:"owner"`
+	UserIDs             []string `json:"useridlist"`
+	Icon                string   `json:"icon"`
+	ConversationTag     int      `json:"conversationTag"`
+	ShowHistoryType     int      `json:"showHistoryType"`     // 0 新成员不可查看历史消息, 1 可查看
+	Searchable          int      `json:"searchable"`          // 0 不可搜索, 1 可搜索
+	ValidationType      int      `json:"validationType"`      // 0 入群不需验证, 1 入群需群主或管理员同意
+	MentionAllAuthority int      `json:"mentionAllAuthority"` // 0 所有人可@所有人, 1 仅群主可@所有人
+	ManagementType      int      `json:"managementType"`      // 0 所有人可管理群, 1 仅群主可管理
+	ChatBannedType      int      `json:"chatBannedType"`      // 0 不禁言, 1 全员禁言
+}
+
+//ChatUpdate is the changes to make to a chat. Empty fields are left unchanged
+type ChatUpdate struct {
+	Name        string
+	Owner       string
+	AddUserIDs  []string
+	DelUserIDs  []string
+	Icon        string // 群头像的media_id
+	MuteAll     *bool  // 全员禁言
+	ShowHistory *bool  // 新成员可查看历史消息
+}
+
+//ChatReadList is a page of the users who have read a chat message
+type ChatReadList struct {
+	UserIDs    []string
+	NextCursor int64 // 为0时没有更多
+}
+
+//GetChat is 获取群会话
+func (c *DingTalkClient) GetChat(chatID string) (*Chat, error) {
+	return c.GetChatContext(context.Background(), chatID)
+}
+
+//GetChatContext is GetChat with a context
+func (c *DingTalkClient) GetChatContext(ctx context.Context, chatID string) (*Chat, error) {
+	var data struct {
+		OAPIResponse
+		ChatInfo Chat `json:"chat_info"`
+	}
+	params := url.Values{}
+	params.Add("chatid", chatID)
+	err := c.httpRPC(ctx, "chat/get", params, nil, &data)
+	return &data.ChatInfo, err
+}
+
+//UpdateChat is 修改群会话
+func (c *DingTalkClient) UpdateChat(chatID string, update ChatUpdate) error {
+	return c.UpdateChatContext(context.Background(), chatID, update)
+}
+
+//UpdateChatContext is UpdateChat with a context
+func (c *DingTalkClient) UpdateChatContext(ctx context.Context, chatID string, update ChatUpdate) error {
+	var data OAPIResponse
+	request := map[string]interface{}{
+		"chatid": chatID,
+	}
+	if update.Name != "" {
+		request["name"] = update.Name
+	}
+	if update.Owner != "" {
+		request["owner"] = update.Owner
+	}
+	if len(update.AddUserIDs) > 0 {
+		request["add_useridlist"] = update.AddUserIDs
+	}
+	if len(update.DelUserIDs) > 0 {
+		request["del_useridlist"] = update.DelUserIDs
+	}
+	if update.Icon != "" {
+		request["icon"] = update.Icon
+	}
+	if update.MuteAll != nil {
+		request["chatBannedType"] = boolToInt(*update.MuteAll)
+	}
+	if update.ShowHistory != nil {
+		request["showHistoryType"] = boolToInt(*update.ShowHistory)
+	}
+	return c.httpRPC(ctx, "chat/update", nil, request, &data)
+}
+
+//GetChatQRCode is 获取入群二维码链接, which userID shares to invite others to the chat
+func (c *DingTalkClient) GetChatQRCode(chatID string, userID string) (string, error) {
+	return c.GetChatQRCodeContext(context.Background(), chatID, userID)
+}
+
+//GetChatQRCodeContext is GetChatQRCode with a context
+func (c *DingTalkClient) GetChatQRCodeContext(ctx context.Context, chatID string, userID string) (string, error) {
+	var data struct {
+		OAPIResponse
+		Result string `json:"result"`
+	}
+	request := map[string]interface{}{
+		"chatid": chatID,
+		"userid": userID,
+	}
+	err := c.httpRPC(ctx, "topapi/chat/qrcode/get", nil, request, &data)
+	return data.Result, err
+}
+
+//GetChatReadList is 查询群消息已读人员列表, returning up to size users from cursor, which starts at 0
+func (c *DingTalkClient) GetChatReadList(messageID string, cursor int64, size int) (*ChatReadList, error) {
+	return c.GetChatReadListContext(context.Background(), messageID, cursor, size)
+}
+
+//GetChatReadListContext is GetChatReadList with a context
+func (c *DingTalkClient) GetChatReadListContext(ctx context.Context, messageID string, cursor int64, size int) (*ChatReadList, error) {
+	var data struct {
+		OAPIResponse
+		NextCursor     int64    `json:"next_cursor"`
+		ReadUserIDList []string `json:"readUserIdList"`
+	}
+	params := url.Values{}
+	params.Add("messageId", messageID)
+	params.Add("cursor", strconv.FormatInt(cursor, 10))
+	params.Add("size", strconv.Itoa(size))
+	err := c.httpRPC(ctx, "chat/getReadList", params, nil, &data)
+	return &ChatReadList{UserIDs: data.ReadUserIDList, NextCursor: data.NextCursor}, err
+}
+
+//DefaultChatReadPageSize is how many readers ChatReaders requests at once, the most chat/getReadList allows
+const DefaultChatReadPageSize = 100
+
+//ChatReadIterator walks through the users who have read a chat message, one page at a time:
+//
+//	it := c.ChatReaders(messageID)
+//	for it.Next(ctx) {
+//		fmt.Println(it.UserID())
+//	}
+//	err := it.Err()
+type ChatReadIterator struct {
+	PageSize int
+
+	client    *DingTalkClient
+	messageID string
+	cursor    int64
+	page      []string
+	userID    string
+	done      bool
+	err       error
+}
+
+//ChatReaders returns an iterator over the users who have read the message sent by SendChatMessage
+func (c *DingTalkClient) ChatReaders(messageID string) *ChatReadIterator {
+	return &ChatReadIterator{
+		PageSize:  DefaultChatReadPageSize,
+		client:    c,
+		messageID: messageID,
+	}
+}
+
+//Next advances to the next reader, fetching another page when needed. It returns
+//false when there are no more readers or a request failed
+func (it *ChatReadIterator) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		page, err := it.client.GetChatReadListContext(ctx, it.messageID, it.cursor, it.PageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = page.UserIDs
+		it.cursor = page.NextCursor
+		it.done = page.NextCursor == 0 || len(page.UserIDs) == 0
+	}
+	it.userID, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+//UserID is the reader Next advanced to
+func (it *ChatReadIterator) UserID() string {
+	return it.userID
+}
+
+//Err is the error which stopped Next, if any
+func (it *ChatReadIterator) Err() error {
+	return it.err
+}
+
+//ChatUnreadMembers returns the members of a chat who have not read the message,
+//e.g. to escalate an alert which went unnoticed. The sender is never included
+func (c *DingTalkClient) ChatUnreadMembers(chatID string, messageID string, sender string) ([]string, error) {
+	return c.ChatUnreadMembersContext(context.Background(), chatID, messageID, sender)
+}
+
+//ChatUnreadMembersContext is ChatUnreadMembers with a context
+func (c *DingTalkClient) ChatUnreadMembersContext(ctx context.Context, chatID string, messageID string, sender string) ([]string, error) {
+	chat, err := c.GetChatContext(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	read := map[string]bool{sender: true}
+	it := c.ChatReaders(messageID)
+	for it.Next(ctx) {
+		read[it.UserID()] = true
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	var unread []string
+	for _, id := range chat.UserIDs {
+		if !read[id] {
+			unread = append(unread, id)
+		}
+	}
+	return unread, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGetChat(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/chat/get" || r.URL.Query().Get("chatid") != "chat1" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		return jsonResponse(200, `{"errcode":0,"chat_info":{"chatid":"chat1","name":"project","owner":"u1",
+			"useridlist":["u1","u2"],"showHistoryType":1,"chatBannedType":0}}`), nil
+	})
+	chat, err := client.GetChat("chat1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Chat{ChatID: "chat1", Name: "project", Owner: "u1", UserIDs: []string{"u1", "u2"}, ShowHistoryType: 1}
+	if !reflect.DeepEqual(chat, expected) {
+		t.Errorf("got %+v, expected %+v", chat, expected)
+	}
+}
+
+func TestUpdateChat(t *testing.T) {
+	var request map[string]interface{}
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &request)
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	muteAll := true
+	err := client.UpdateChat("chat1", ChatUpdate{
+		Name:       "renamed",
+		AddUserIDs: []string{"u3"},
+		MuteAll:    &muteAll,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"chatid":"chat1","name":"renamed","add_useridlist":["u3"],"chatBannedType":1}`), &expected)
+	if !reflect.DeepEqual(request, expected) {
+		t.Errorf("got %v, expected %v", request, expected)
+	}
+}
+
+func TestGetChatReadList(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		if r.URL.Path != "/chat/getReadList" || q.Get("messageId") != "msg1" || q.Get("cursor") != "0" || q.Get("size") != "100" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		return jsonResponse(200, `{"errcode":0,"next_cursor":42,"readUserIdList":["u1"]}`), nil
+	})
+	page, err := client.GetChatReadList("msg1", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != 42 || !reflect.DeepEqual(page.UserIDs, []string{"u1"}) {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestChatReadersAndUnreadMembers(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/chat/send":
+			return jsonResponse(200, `{"errcode":0,"messageId":"msg1"}`), nil
+		case "/chat/get":
+			return jsonResponse(200, `{"errcode":0,"chat_info":{"useridlist":["sender","u1","u2","u3","u4"]}}`), nil
+		case "/chat/getReadList":
+			if r.URL.Query().Get("cursor") == "0" {
+				return jsonResponse(200, `{"errcode":0,"next_cursor":7,"readUserIdList":["u1"]}`), nil
+			}
+			return jsonResponse(200, `{"errcode":0,"readUserIdList":["u3"]}`), nil
+		}
+		t.Errorf("unexpected request %s", r.URL)
+		return jsonResponse(404, ""), nil
+	})
+
+	messageID, err := client.SendChatMessage("chat1", "sender", TextMessage{Content: "alert"})
+	if err != nil || messageID != "msg1" {
+		t.Fatalf("unexpected message id %q %v", messageID, err)
+	}
+
+	var readers []string
+	it := client.ChatReaders(messageID)
+	for it.Next(context.Background()) {
+		readers = append(readers, it.UserID())
+	}
+	if it.Err() != nil || !reflect.DeepEqual(readers, []string{"u1", "u3"}) {
+		t.Errorf("unexpected readers %v %v", readers, it.Err())
+	}
+
+	unread, err := client.ChatUnreadMembers("chat1", messageID, "sender")
+	if err != nil || !reflect.DeepEqual(unread, []string{"u2", "u4"}) {
+		t.Errorf("unexpected unread members %v %v", unread, err)
+	}
+}
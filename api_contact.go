@@ -1,6 +1,7 @@
 package godingtalk
 
 import (
+	"context"
     "fmt"
     "net/url"
 	"gopkg.in/square/go-jose.v1/json"
@@ -105,31 +106,51 @@ type ExternalUserLabelGroup struct {
 
 // DepartmentList is 获取部门列表
 func (c *DingTalkClient) DepartmentList() (DepartmentList, error) {
+	return c.DepartmentListContext(context.Background())
+}
+
+//DepartmentListContext is DepartmentList with a context
+func (c *DingTalkClient) DepartmentListContext(ctx context.Context) (DepartmentList, error) {
     var data DepartmentList
-    err := c.httpRPC("department/list", nil, nil, &data)   
+    err := c.httpRPC(ctx, "department/list", nil, nil, &data)   
     return data, err
 }
 
 //DepartmentDetail is 获取部门详情
 func (c *DingTalkClient) DepartmentDetail(id int) (*Department, error) {
+	return c.DepartmentDetailContext(context.Background(), id)
+}
+
+//DepartmentDetailContext is DepartmentDetail with a context
+func (c *DingTalkClient) DepartmentDetailContext(ctx context.Context, id int) (*Department, error) {
     var data Department
     params := url.Values{}
     params.Add("id", fmt.Sprintf("%d", id))
-    err :=c.httpRPC("department/get", params, nil, &data)
+    err :=c.httpRPC(ctx, "department/get", params, nil, &data)
     return &data, err
 }
 
 //UserList is 获取部门成员
 func (c *DingTalkClient) UserList(departmentID int) (UserList, error) {
+	return c.UserListContext(context.Background(), departmentID)
+}
+
+//UserListContext is UserList with a context
+func (c *DingTalkClient) UserListContext(ctx context.Context, departmentID int) (UserList, error) {
     var data UserList
     params := url.Values{}
     params.Add("department_id", fmt.Sprintf("%d", departmentID))    
-    err :=c.httpRPC("user/list", params, nil, &data)
+    err :=c.httpRPC(ctx, "user/list", params, nil, &data)
     return data, err
 }
 
 //CreateChat is 
 func (c *DingTalkClient) CreateChat(name string, owner string, useridlist []string) (string, error) {
+	return c.CreateChatContext(context.Background(), name, owner, useridlist)
+}
+
+//CreateChatContext is CreateChat with a context
+func (c *DingTalkClient) CreateChatContext(ctx context.Context, name string, owner string, useridlist []string) (string, error) {
     var data struct {
         OAPIResponse
         Chatid string
@@ -139,15 +160,20 @@ func (c *DingTalkClient) CreateChat(name string, owner string, useridlist []stri
         "owner":owner,
         "useridlist":useridlist,     
     }
-    err :=c.httpRPC("chat/create", nil, request, &data)
+    err :=c.httpRPC(ctx, "chat/create", nil, request, &data)
     return data.Chatid, err
 }
 
 func (c *DingTalkClient) UserDetail(id string) (*User, error) {
+	return c.UserDetailContext(context.Background(), id)
+}
+
+//UserDetailContext is UserDetail with a context
+func (c *DingTalkClient) UserDetailContext(ctx context.Context, id string) (*User, error) {
 	var user User
 	params := url.Values{}
 	params.Add("userid", id)
-	err := c.httpRPC("user/get", params, nil, &user)
+	err := c.httpRPC(ctx, "user/get", params, nil, &user)
 	return &user, err
 }
 
@@ -161,15 +187,25 @@ type UserInfo struct {
 
 //UserInfoByCode 校验免登录码并换取用户身份
 func (c *DingTalkClient) UserInfoByCode(code string) (*UserInfo, error) {
+	return c.UserInfoByCodeContext(context.Background(), code)
+}
+
+//UserInfoByCodeContext is UserInfoByCode with a context
+func (c *DingTalkClient) UserInfoByCodeContext(ctx context.Context, code string) (*UserInfo, error) {
     var data UserInfo
     params := url.Values{}
     params.Add("code", code)
-    err := c.httpRPC("user/getuserinfo", params, nil, &data)
+    err := c.httpRPC(ctx, "user/getuserinfo", params, nil, &data)
     return &data, err
 }
 
 //UseridByUnionId 通过UnionId获取玩家Userid
 func (c *DingTalkClient) UseridByUnionId(unionid string) (string, error) {
+	return c.UseridByUnionIdContext(context.Background(), unionid)
+}
+
+//UseridByUnionIdContext is UseridByUnionId with a context
+func (c *DingTalkClient) UseridByUnionIdContext(ctx context.Context, unionid string) (string, error) {
     var data struct {
 		OAPIResponse
 		UserID string `json:"userid"`
@@ -177,7 +213,7 @@ func (c *DingTalkClient) UseridByUnionId(unionid string) (string, error) {
 
     params := url.Values{}
     params.Add("unionid", unionid)
-    err := c.httpRPC("user/getUseridByUnionid", params, nil, &data)
+    err := c.httpRPC(ctx, "user/getUseridByUnionid", params, nil, &data)
 	if err!=nil {
 		return "",err
 	}
@@ -186,6 +222,11 @@ func (c *DingTalkClient) UseridByUnionId(unionid string) (string, error) {
 }
 
 func (c *DingTalkClient) CreateExternalUser(euser *ExternalUser) (userID string, err error) {
+	return c.CreateExternalUserContext(context.Background(), euser)
+}
+
+//CreateExternalUserContext is CreateExternalUser with a context
+func (c *DingTalkClient) CreateExternalUserContext(ctx context.Context, euser *ExternalUser) (userID string, err error) {
 	var rep struct {
 		TaobaoOAPIResponse
 		UserID string `json:"userid"`
@@ -194,7 +235,7 @@ func (c *DingTalkClient) CreateExternalUser(euser *ExternalUser) (userID string,
 	d, _ := json.Marshal(euser)
 	params := url.Values{}
 	params.Add("contact", string(d))
-	err = c.httpTaobaoRPC("dingtalk.corp.ext.add", params, &rep)
+	err = c.httpTaobaoRPC(ctx, "dingtalk.corp.ext.add", params, &rep)
 	if err != nil {
 		return "", err
 	}
@@ -204,6 +245,11 @@ func (c *DingTalkClient) CreateExternalUser(euser *ExternalUser) (userID string,
 }
 
 func (c *DingTalkClient) ExternalUserList(offset, size int) ([]ExternalUser, error) {
+	return c.ExternalUserListContext(context.Background(), offset, size)
+}
+
+//ExternalUserListContext is ExternalUserList with a context
+func (c *DingTalkClient) ExternalUserListContext(ctx context.Context, offset, size int) ([]ExternalUser, error) {
 	type User struct {
 		UserID string `json:"userId"`
 		Name string `json:"name"`
@@ -229,7 +275,7 @@ func (c *DingTalkClient) ExternalUserList(offset, size int) ([]ExternalUser, err
 	params := url.Values{}
 	params.Add("size", strconv.Itoa(size))
 	params.Add("offset", strconv.Itoa(offset))
-	err := c.httpTaobaoRPC("dingtalk.corp.ext.list", params, &rep)
+	err := c.httpTaobaoRPC(ctx, "dingtalk.corp.ext.list", params, &rep)
 	if err != nil {
 		return nil, err
 	}
@@ -261,6 +307,11 @@ func (c *DingTalkClient) ExternalUserList(offset, size int) ([]ExternalUser, err
 }
 
 func (c *DingTalkClient) ExternalUserLabelGroups(offset, size int) ([]ExternalUserLabelGroup, error) {
+	return c.ExternalUserLabelGroupsContext(context.Background(), offset, size)
+}
+
+//ExternalUserLabelGroupsContext is ExternalUserLabelGroups with a context
+func (c *DingTalkClient) ExternalUserLabelGroupsContext(ctx context.Context, offset, size int) ([]ExternalUserLabelGroup, error) {
 	var rep struct{
 		TaobaoOAPIResponse
 		Result string
@@ -269,7 +320,7 @@ func (c *DingTalkClient) ExternalUserLabelGroups(offset, size int) ([]ExternalUs
 	params := url.Values{}
 	params.Add("size", strconv.Itoa(size))
 	params.Add("offset", strconv.Itoa(offset))
-	err := c.httpTaobaoRPC("dingtalk.corp.ext.listlabelgroups", params, &rep)
+	err := c.httpTaobaoRPC(ctx, "dingtalk.corp.ext.listlabelgroups", params, &rep)
 	if err != nil {
 		return nil, err
 	}
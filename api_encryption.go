@@ -1,5 +1,7 @@
 package godingtalk
 
+import "context"
+
 //DataMessage 服务端加密、解密消息
 type DataMessage struct {
 	OAPIResponse
@@ -9,11 +11,16 @@ type DataMessage struct {
 
 //Encrypt is 服务端加密 
 func (c *DingTalkClient) Encrypt(str string) (string, error) {
+	return c.EncryptContext(context.Background(), str)
+}
+
+//EncryptContext is Encrypt with a context
+func (c *DingTalkClient) EncryptContext(ctx context.Context, str string) (string, error) {
 	var data DataMessage
 	request := map[string]interface{}{
 		"data":  str,
 	}
-	err := c.httpRPC("encryption/encrypt", nil, request, &data)
+	err := c.httpRPC(ctx, "encryption/encrypt", nil, request, &data)
     if err!=nil {
         return "", err
     }
@@ -22,11 +29,16 @@ func (c *DingTalkClient) Encrypt(str string) (string, error) {
 
 //Decrypt is 服务端解密
 func (c *DingTalkClient) Decrypt(str string) (string, error) {
+	return c.DecryptContext(context.Background(), str)
+}
+
+//DecryptContext is Decrypt with a context
+func (c *DingTalkClient) DecryptContext(ctx context.Context, str string) (string, error) {
 	var data DataMessage
 	request := map[string]interface{}{
 		"data":  str,
 	}
-	err := c.httpRPC("encryption/decrypt", nil, request, &data)
+	err := c.httpRPC(ctx, "encryption/decrypt", nil, request, &data)
     if err!=nil {
         return "", err
     }
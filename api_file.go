@@ -2,6 +2,7 @@ package godingtalk
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -27,12 +28,17 @@ func (f *FileResponse) getWriter() io.Writer {
 
 //CreateFile is to create a new file in Ding Space
 func (c *DingTalkClient) CreateFile(size int64) (file FileResponse, err error) {
+	return c.CreateFileContext(context.Background(), size)
+}
+
+//CreateFileContext is CreateFile with a context
+func (c *DingTalkClient) CreateFileContext(ctx context.Context, size int64) (file FileResponse, err error) {
 	buf := bytes.Buffer{}
 	file = FileResponse{
 		Writer: &buf,
 	}
 	params := url.Values{}
 	params.Add("size", fmt.Sprintf("%d", size))
-	err = c.httpRPC("file/upload/create", params, nil, &file)
+	err = c.httpRPC(ctx, "file/upload/create", params, nil, &file)
 	return file, err
 }
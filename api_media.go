@@ -1,6 +1,7 @@
 package godingtalk
 
 import (
+	"context"
 	"io"
 	"net/url"
 )
@@ -19,6 +20,11 @@ func (m *MediaResponse) getWriter() io.Writer {
 
 //UploadMedia is to upload media file to DingTalk
 func (c *DingTalkClient) UploadMedia(mediaType string, filename string, reader io.Reader) (media MediaResponse, err error) {
+	return c.UploadMediaContext(context.Background(), mediaType, filename, reader)
+}
+
+//UploadMediaContext is UploadMedia with a context
+func (c *DingTalkClient) UploadMediaContext(ctx context.Context, mediaType string, filename string, reader io.Reader) (media MediaResponse, err error) {
 	upload := UploadFile{
 		FieldName: "media",
 		FileName:  filename,
@@ -26,16 +32,21 @@ func (c *DingTalkClient) UploadMedia(mediaType string, filename string, reader i
 	}
 	params := url.Values{}
 	params.Add("type", mediaType)
-	err = c.httpRPC("media/upload", params, upload, &media)
+	err = c.httpRPC(ctx, "media/upload", params, upload, &media)
 	return media, err
 }
 
 //DownloadMedia is to download a media file from DingTalk
 func (c *DingTalkClient) DownloadMedia(mediaID string, write io.Writer) error {
+	return c.DownloadMediaContext(context.Background(), mediaID, write)
+}
+
+//DownloadMediaContext is DownloadMedia with a context
+func (c *DingTalkClient) DownloadMediaContext(ctx context.Context, mediaID string, write io.Writer) error {
 	var data MediaResponse
 	data.Writer = write
 	params := url.Values{}
 	params.Add("media_id", mediaID)
-	err := c.httpRPC("media/get", params, nil, &data)
+	err := c.httpRPC(ctx, "media/get", params, nil, &data)
 	return err
 }
@@ -1,156 +1,163 @@
 package godingtalk
 
-//SendAppMessage is 发送企业会话消息
-func (c *DingTalkClient) SendAppMessage(agentID string, touser string, msg string) error {
+import "context"
+
+//SendWorkNotice is 发送工作通知消息, touser 为以 | 分隔的用户ID列表
+func (c *DingTalkClient) SendWorkNotice(agentID string, touser string, msg Message) error {
+	return c.SendWorkNoticeContext(context.Background(), agentID, touser, msg)
+}
+
+//SendWorkNoticeContext is SendWorkNotice with a context
+func (c *DingTalkClient) SendWorkNoticeContext(ctx context.Context, agentID string, touser string, msg Message) error {
 	if agentID == "" {
 		agentID = c.AgentID
 	}
 	var data OAPIResponse
-	request := map[string]interface{}{
-		"touser":  touser,
-		"agentid": agentID,
-		"msgtype": "text",
-		"text": map[string]interface{}{
-			"content": msg,
-		},
-	}
-	err := c.httpRPC("message/send", nil, request, &data)
+	request := messagePayload(msg)
+	request["touser"] = touser
+	request["agentid"] = agentID
+	err := c.httpRPC(ctx, "message/send", nil, request, &data)
 	return err
 }
 
+//SendChatMessage is 发送群消息, and returns the id of the message, which GetChatReadList
+//and ChatReaders take to find who has read it
+func (c *DingTalkClient) SendChatMessage(chatID string, sender string, msg Message) (string, error) {
+	return c.SendChatMessageContext(context.Background(), chatID, sender, msg)
+}
+
+//SendChatMessageContext is SendChatMessage with a context
+func (c *DingTalkClient) SendChatMessageContext(ctx context.Context, chatID string, sender string, msg Message) (string, error) {
+	var data struct {
+		OAPIResponse
+		MessageID string `json:"messageId"`
+	}
+	request := messagePayload(msg)
+	request["chatid"] = chatID
+	request["sender"] = sender
+	err := c.httpRPC(ctx, "chat/send", nil, request, &data)
+	return data.MessageID, err
+}
+
+//SendAppMessage is 发送企业会话消息
+func (c *DingTalkClient) SendAppMessage(agentID string, touser string, msg string) error {
+	return c.SendAppMessageContext(context.Background(), agentID, touser, msg)
+}
+
+//SendAppMessageContext is SendAppMessage with a context
+func (c *DingTalkClient) SendAppMessageContext(ctx context.Context, agentID string, touser string, msg string) error {
+	return c.SendWorkNoticeContext(ctx, agentID, touser, TextMessage{Content: msg})
+}
+
 //SendAppOAMessage is 发送OA消息
 func (c *DingTalkClient) SendAppOAMessage(agentID string, touser string, msg OAMessage) error {
-	if agentID == "" {
-		agentID = c.AgentID
-	}
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"touser":  touser,
-		"agentid": agentID,
-		"msgtype": "oa",
-		"oa": msg,
-	}
-	err := c.httpRPC("message/send", nil, request, &data)
-	return err
+	return c.SendAppOAMessageContext(context.Background(), agentID, touser, msg)
+}
+
+//SendAppOAMessageContext is SendAppOAMessage with a context
+func (c *DingTalkClient) SendAppOAMessageContext(ctx context.Context, agentID string, touser string, msg OAMessage) error {
+	return c.SendWorkNoticeContext(ctx, agentID, touser, msg)
 }
 
 //SendAppLinkMessage is 发送企业会话链接消息
 func (c *DingTalkClient) SendAppLinkMessage(agentID, touser string, title, text string, picUrl, url string) error {
-	if agentID == "" {
-		agentID = c.AgentID
-	}
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"touser":  touser,
-		"agentid": agentID,
-		"msgtype": "link",
-		"link": map[string]string{
-			"messageUrl": url,
-			"picUrl":     picUrl,
-			"title":      title,
-			"text":       text,
-		},
-	}
-	err := c.httpRPC("message/send", nil, request, &data)
-	return err
+	return c.SendAppLinkMessageContext(context.Background(), agentID, touser, title, text, picUrl, url)
+}
+
+//SendAppLinkMessageContext is SendAppLinkMessage with a context
+func (c *DingTalkClient) SendAppLinkMessageContext(ctx context.Context, agentID, touser string, title, text string, picUrl, url string) error {
+	return c.SendWorkNoticeContext(ctx, agentID, touser, LinkMessage{
+		MessageURL: url,
+		PicURL:     picUrl,
+		Title:      title,
+		Text:       text,
+	})
 }
 
 //SendTextMessage is 发送普通文本消息
 func (c *DingTalkClient) SendTextMessage(sender string, cid string, msg string) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "text",
-		"text": map[string]interface{}{
-			"content": msg,
-		},
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendTextMessageContext(context.Background(), sender, cid, msg)
+}
+
+//SendTextMessageContext is SendTextMessage with a context
+func (c *DingTalkClient) SendTextMessageContext(ctx context.Context, sender string, cid string, msg string) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, TextMessage{Content: msg})
 	return err
 }
 
 //SendImageMessage is 发送图片消息
 func (c *DingTalkClient) SendImageMessage(sender string, cid string, mediaID string) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "image",
-		"image": map[string]string{
-			"media_id": mediaID,
-		},
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendImageMessageContext(context.Background(), sender, cid, mediaID)
+}
+
+//SendImageMessageContext is SendImageMessage with a context
+func (c *DingTalkClient) SendImageMessageContext(ctx context.Context, sender string, cid string, mediaID string) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, ImageMessage{MediaID: mediaID})
 	return err
 }
 
 //SendVoiceMessage is 发送语音消息
 func (c *DingTalkClient) SendVoiceMessage(sender string, cid string, mediaID string, duration string) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "voice",
-		"voice": map[string]string{
-			"media_id": mediaID,
-			"duration": duration,
-		},
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendVoiceMessageContext(context.Background(), sender, cid, mediaID, duration)
+}
+
+//SendVoiceMessageContext is SendVoiceMessage with a context
+func (c *DingTalkClient) SendVoiceMessageContext(ctx context.Context, sender string, cid string, mediaID string, duration string) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, VoiceMessage{MediaID: mediaID, Duration: duration})
 	return err
 }
 
 //SendFileMessage is 发送文件消息
 func (c *DingTalkClient) SendFileMessage(sender string, cid string, mediaID string) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "file",
-		"file": map[string]string{
-			"media_id": mediaID,
-		},
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendFileMessageContext(context.Background(), sender, cid, mediaID)
+}
+
+//SendFileMessageContext is SendFileMessage with a context
+func (c *DingTalkClient) SendFileMessageContext(ctx context.Context, sender string, cid string, mediaID string) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, FileMessage{MediaID: mediaID})
 	return err
 }
 
 //SendLinkMessage is 发送链接消息
 func (c *DingTalkClient) SendLinkMessage(sender string, cid string, mediaID string, url string, title string, text string) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "link",
-		"link": map[string]string{
-			"messageUrl": url,
-			"picUrl":     mediaID,
-			"title":      title,
-			"text":       text,
-		},
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendLinkMessageContext(context.Background(), sender, cid, mediaID, url, title, text)
+}
+
+//SendLinkMessageContext is SendLinkMessage with a context
+func (c *DingTalkClient) SendLinkMessageContext(ctx context.Context, sender string, cid string, mediaID string, url string, title string, text string) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, LinkMessage{
+		MessageURL: url,
+		PicURL:     mediaID,
+		Title:      title,
+		Text:       text,
+	})
 	return err
 }
 
-//OAMessage is the Message for OA
+//OAMessage is the Message for OA. Empty head, body and rich parts are left out of its JSON
 type OAMessage struct {
-	URL  string `json:"message_url"`
-	PcURL string `json:"pc_message_url"`
-	Head struct {
-		BgColor string `json:"bgcolor,omitempty"`
-		Text    string `json:"text,omitempty"`
-	} `json:"head,omitempty"`
-	Body struct {
-		Title     string          `json:"title,omitempty"`
-		Form      []OAMessageForm `json:"form,omitempty"`
-		Rich      OAMessageRich   `json:"rich,omitempty"`
-		Content   string          `json:"content,omitempty"`
-		Image     string          `json:"image,omitempty"`
-		FileCount int             `json:"file_count,omitempty"`
-		Author    string          `json:"author,omitempty"`
-	} `json:"body,omitempty"`
+	URL       string        `json:"message_url"`
+	PcURL     string        `json:"pc_message_url,omitempty"`
+	Head      OAMessageHead `json:"head"`
+	Body      OAMessageBody `json:"body"`
+	StatusBar *OAStatusBar  `json:"status_bar,omitempty"`
+}
+
+//OAMessageHead is the head of an OAMessage
+type OAMessageHead struct {
+	BgColor string `json:"bgcolor,omitempty"` // ARGB, 如 FFBBBBBB
+	Text    string `json:"text,omitempty"`
+}
+
+//OAMessageBody is the body of an OAMessage
+type OAMessageBody struct {
+	Title     string          `json:"title,omitempty"`
+	Form      []OAMessageForm `json:"form,omitempty"`
+	Rich      OAMessageRich   `json:"rich"`
+	Content   string          `json:"content,omitempty"`
+	Image     string          `json:"image,omitempty"`
+	FileCount int             `json:"file_count,omitempty"`
+	Author    string          `json:"author,omitempty"`
 }
 
 type OAMessageForm struct {
@@ -160,7 +167,13 @@ type OAMessageForm struct {
 
 type OAMessageRich struct {
 	Num  string `json:"num,omitempty"`
-	Unit string `json:"body,omitempty"`
+	Unit string `json:"unit,omitempty"`
+}
+
+//OAStatusBar is the status bar of an OA work notice, which UpdateWorkNoticeStatusBar changes after it is sent
+type OAStatusBar struct {
+	Value   string `json:"status_value"`
+	BgColor string `json:"status_bg,omitempty"` // ARGB, 如 0xFF78C06E
 }
 
 func (m *OAMessage) AppendFormItem(key string, value string) {
@@ -175,13 +188,11 @@ func (m *OAMessage) AppendFormItem(key string, value string) {
 
 //SendOAMessage is 发送OA消息
 func (c *DingTalkClient) SendOAMessage(sender string, cid string, msg OAMessage) error {
-	var data OAPIResponse
-	request := map[string]interface{}{
-		"chatid":  cid,
-		"sender":  sender,
-		"msgtype": "oa",
-		"oa":      msg,
-	}
-	err := c.httpRPC("chat/send", nil, request, &data)
+	return c.SendOAMessageContext(context.Background(), sender, cid, msg)
+}
+
+//SendOAMessageContext is SendOAMessage with a context
+func (c *DingTalkClient) SendOAMessageContext(ctx context.Context, sender string, cid string, msg OAMessage) error {
+	_, err := c.SendChatMessageContext(ctx, cid, sender, msg)
 	return err
 }
@@ -1,20 +1,129 @@
 package godingtalk
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//SendRobotMessage can send any message supported by robots to a group chat
+func (c *DingTalkClient) SendRobotMessage(accessToken string, msg Message) error {
+	return c.SendRobotMessageContext(context.Background(), accessToken, msg)
+}
+
+//SendRobotMessageContext is SendRobotMessage with a context
+func (c *DingTalkClient) SendRobotMessageContext(ctx context.Context, accessToken string, msg Message) error {
+	var data OAPIResponse
+	params := url.Values{}
+	params.Add("access_token", accessToken)
+	err := c.httpRPC(ctx, "robot/send", params, robotMessagePayload(msg), &data)
+	return err
+}
+
 //SendRobotTextMessage can send a text message to a group chat
 func (c *DingTalkClient) SendRobotTextMessage(accessToken string, msg string) error {
+	return c.SendRobotTextMessageContext(context.Background(), accessToken, msg)
+}
+
+//SendRobotTextMessageContext is SendRobotTextMessage with a context
+func (c *DingTalkClient) SendRobotTextMessageContext(ctx context.Context, accessToken string, msg string) error {
+	return c.SendRobotMessageContext(ctx, accessToken, TextMessage{Content: msg})
+}
+
+//Robot is a group chat robot (自定义机器人). It only needs the webhook of the robot,
+//and the secret when the robot is secured by 加签, so it works without corp credentials
+type Robot struct {
+	Webhook    string
+	Secret     string
+	HTTPClient *http.Client
+}
+
+//RobotAt is who a robot message mentions
+type RobotAt struct {
+	AtMobiles []string `json:"atMobiles,omitempty"`
+	AtUserIDs []string `json:"atUserIds,omitempty"`
+	IsAtAll   bool     `json:"isAtAll,omitempty"`
+}
+
+//NewRobot creates a Robot from its webhook URL, or only the access_token of the webhook
+func NewRobot(webhook string, secret string) *Robot {
+	if !strings.HasPrefix(webhook, "http://") && !strings.HasPrefix(webhook, "https://") {
+		webhook = BASE_URL + "robot/send?access_token=" + url.QueryEscape(webhook)
+	}
+	return &Robot{
+		Webhook:    webhook,
+		Secret:     secret,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+//Send sends msg to the group chat of the robot, mentioning the users in at if it is not nil
+func (r *Robot) Send(msg Message, at *RobotAt) error {
+	return r.SendContext(context.Background(), msg, at)
+}
+
+//SendContext is Send with a context
+func (r *Robot) SendContext(ctx context.Context, msg Message, at *RobotAt) error {
+	payload := robotMessagePayload(msg)
+	if at != nil {
+		payload["at"] = at
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	webhook, err := r.signedWebhook(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", typeJSON+"; charset=UTF-8")
+	req.Header.Set("User-Agent", "godingtalk/"+VERSION)
+
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 	var data OAPIResponse
-    params := url.Values{}
-    params.Add("access_token", accessToken)	
-	request := map[string]interface{}{
-		"msgtype": "text",
-		"text": map[string]interface{}{
-			"content": msg,
-		},
-	}
-	err := c.httpRPC("robot/send", params, request, &data)
-	return err
-}
\ No newline at end of file
+	return decodeResponse(resp, "robot/send", &data)
+}
+
+//signedWebhook adds timestamp and sign to the webhook when the robot has a secret
+func (r *Robot) signedWebhook(now time.Time) (string, error) {
+	if r.Secret == "" {
+		return r.Webhook, nil
+	}
+	u, err := url.Parse(r.Webhook)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", robotSign(timestamp, r.Secret))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+//robotSign is the base64 encoded HMAC-SHA256 of timestamp+"\n"+secret, keyed by secret
+func robotSign(timestamp string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
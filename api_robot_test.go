@@ -0,0 +1,73 @@
+package godingtalk
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRobotSignsAndMentions(t *testing.T) {
+	var query map[string][]string
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	robot := NewRobot(srv.URL+"/robot/send?access_token=tok", "SECxyz")
+	at := &RobotAt{AtMobiles: []string{"13800000000"}, IsAtAll: true}
+	if err := robot.Send(FeedCardMessage{Links: []FeedCardLink{{Title: "t", MessageURL: "u", PicURL: "p"}}}, at); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := query["timestamp"][0]
+	if query["access_token"][0] != "tok" || query["sign"][0] != robotSign(timestamp, "SECxyz") {
+		t.Errorf("unexpected query %v", query)
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"msgtype":"feedCard","feedCard":{"links":[{"title":"t","messageURL":"u","picURL":"p"}]},
+		"at":{"atMobiles":["13800000000"],"isAtAll":true}}`), &expected)
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("got %v, expected %v", body, expected)
+	}
+}
+
+func TestRobotSign(t *testing.T) {
+	// hmac.new(b"SEC", b"1577262236757\nSEC", hashlib.sha256) as in the DingTalk documentation
+	if sign := robotSign("1577262236757", "SEC"); sign != "FCrkybxczqYG4jomAMzHQsyxBVrxsDxfRW1CGgX+1rk=" {
+		t.Errorf("unexpected sign %s", sign)
+	}
+}
+
+func TestRobotError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["sign"]; ok {
+			t.Error("a robot without secret must not sign")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+	}))
+	defer srv.Close()
+
+	err := NewRobot(srv.URL, "").Send(TextMessage{Content: "hi"}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrCode != 310000 || apiErr.Path != "robot/send" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewRobotFromToken(t *testing.T) {
+	robot := NewRobot("abc", "")
+	if !strings.HasPrefix(robot.Webhook, BASE_URL+"robot/send?access_token=abc") {
+		t.Errorf("unexpected webhook %s", robot.Webhook)
+	}
+}
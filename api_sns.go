@@ -2,6 +2,7 @@
 package godingtalk
 
 import(
+	"context"
 	"net/url"
 )
 
@@ -10,17 +11,29 @@ import(
 //    根据和赤司(钉钉开发者)的沟通，ACCESS_TOKEN只有两个小时的有效期
 //    但是目前接口貌似没有返回过期时间相关的信息，因此所有相关的调用都需要强制刷新
 func (c *DingTalkClient) RefreshSnsAccessToken() error {
+	return c.RefreshSnsAccessTokenContext(context.Background())
+}
+
+//RefreshSnsAccessTokenContext is RefreshSnsAccessToken with a context
+func (c *DingTalkClient) RefreshSnsAccessTokenContext(ctx context.Context) error {
+	_, err := c.refreshSnsAccessToken(ctx)
+	return err
+}
+
+func (c *DingTalkClient) refreshSnsAccessToken(ctx context.Context) (string, error) {
 	var data AccessTokenResponse
 
 	params := url.Values{}
 	params.Add("appid", c.SnsAppID)
 	params.Add("appsecret", c.SnsAppSecret)
 
-	err := c.httpRPC("sns/gettoken", params, nil, &data)
+	err := c.httpRPC(ctx, "sns/gettoken", params, nil, &data)
 	if err==nil {
+		c.Lock()
 		c.SnsAccessToken = data.AccessToken
+		c.Unlock()
 	}
-	return err
+	return data.AccessToken, err
 }
 
 //获取用户授权的持久授权码返回信息
@@ -33,17 +46,22 @@ type SnsPersistentCodeResponse struct {
 
 //获取用户授权的持久授权码
 func (c *DingTalkClient) GetSnsPersistentCode(tmpAuthCode string) (string, string, string, error) {
-	c.RefreshSnsAccessToken()
+	return c.GetSnsPersistentCodeContext(context.Background(), tmpAuthCode)
+}
+
+//GetSnsPersistentCodeContext is GetSnsPersistentCode with a context
+func (c *DingTalkClient) GetSnsPersistentCodeContext(ctx context.Context, tmpAuthCode string) (string, string, string, error) {
+	token, _ := c.refreshSnsAccessToken(ctx)
 
 	params := url.Values{}
-	params.Add("access_token", c.SnsAccessToken)
+	params.Add("access_token", token)
 
 	request := map[string]interface{}{
 		"tmp_auth_code": tmpAuthCode,
 	}
 
 	var data SnsPersistentCodeResponse
-	err := c.httpRequest("sns/get_persistent_code", params, request, &data)
+	err := c.httpRequest(ctx, "sns/get_persistent_code", params, request, &data)
 	if err!=nil {
 		return "","","",err
 	}
@@ -59,10 +77,15 @@ type SnsTokenResponse struct {
 
 //获取用户授权的SNS_TOKEN
 func (c *DingTalkClient) GetSnsToken(openid, persistentCode string) (string, error) {
-	c.RefreshSnsAccessToken()
+	return c.GetSnsTokenContext(context.Background(), openid, persistentCode)
+}
+
+//GetSnsTokenContext is GetSnsToken with a context
+func (c *DingTalkClient) GetSnsTokenContext(ctx context.Context, openid, persistentCode string) (string, error) {
+	token, _ := c.refreshSnsAccessToken(ctx)
 
 	params := url.Values{}
-	params.Add("access_token", c.SnsAccessToken)
+	params.Add("access_token", token)
 
 	request := map[string]interface{}{
 		"openid": openid,
@@ -70,7 +93,7 @@ func (c *DingTalkClient) GetSnsToken(openid, persistentCode string) (string, err
 	}
 
 	var data SnsTokenResponse
-	err := c.httpRequest("sns/get_sns_token", params, request, &data)
+	err := c.httpRequest(ctx, "sns/get_sns_token", params, request, &data)
 	if err!=nil {
 		return "", err
 	}
@@ -98,12 +121,17 @@ type SnsUserInfoResponse struct {
 
 //获取用户授权的个人信息
 func (c *DingTalkClient) GetSnsUserInfo(snsToken string) (SnsUserInfoResponse, error) {
-	c.RefreshSnsAccessToken()
+	return c.GetSnsUserInfoContext(context.Background(), snsToken)
+}
+
+//GetSnsUserInfoContext is GetSnsUserInfo with a context
+func (c *DingTalkClient) GetSnsUserInfoContext(ctx context.Context, snsToken string) (SnsUserInfoResponse, error) {
+	c.RefreshSnsAccessTokenContext(ctx)
 
 	params := url.Values{}
 	params.Add("sns_token", snsToken)
 
 	var data SnsUserInfoResponse
-	err := c.httpRequest("sns/getuserinfo", params, nil, &data)
+	err := c.httpRequest(ctx, "sns/getuserinfo", params, nil, &data)
 	return data, err
 }
@@ -0,0 +1,192 @@
+package godingtalk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//MaxWorkNoticeUsers is how many users a single asynchronous work notice can be sent to
+const MaxWorkNoticeUsers = 5000
+
+//Work notice task states reported by GetWorkNoticeProgress
+const (
+	WorkNoticeStatusPending    = 0 // 未开始
+	WorkNoticeStatusProcessing = 1 // 处理中
+	WorkNoticeStatusDone       = 2 // 处理完毕
+)
+
+//WorkNotice is 工作通知消息, sent asynchronously to users, departments or the whole corp
+type WorkNotice struct {
+	AgentID   string // 为空时使用 DingTalkClient.AgentID
+	UserIDs   []string
+	DeptIDs   []int
+	ToAllUser bool
+	Message   Message
+}
+
+//WorkNoticeProgress is 工作通知消息的发送进度
+type WorkNoticeProgress struct {
+	Percent int `json:"progress_in_percent"`
+	Status  int `json:"status"`
+}
+
+//WorkNoticeResult is 工作通知消息的发送结果
+type WorkNoticeResult struct {
+	InvalidUserIDs   []string `json:"invalid_user_id_list"`
+	ForbiddenUserIDs []string `json:"forbidden_user_id_list"` // 因发送消息过于频繁或超量而被流控过滤的用户
+	FailedUserIDs    []string `json:"failed_user_id_list"`
+	ReadUserIDs      []string `json:"read_user_id_list"`
+	UnreadUserIDs    []string `json:"unread_user_id_list"`
+	InvalidDeptIDs   []int    `json:"invalid_dept_id_list"`
+}
+
+func (c *DingTalkClient) agentID(agentID string) (int64, error) {
+	if agentID == "" {
+		agentID = c.AgentID
+	}
+	id, err := strconv.ParseInt(agentID, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid agent id: " + agentID)
+	}
+	return id, nil
+}
+
+//AsyncSendWorkNotice is 异步发送工作通知消息, and returns the id of the send task
+func (c *DingTalkClient) AsyncSendWorkNotice(notice WorkNotice) (int64, error) {
+	return c.AsyncSendWorkNoticeContext(context.Background(), notice)
+}
+
+//AsyncSendWorkNoticeContext is AsyncSendWorkNotice with a context
+func (c *DingTalkClient) AsyncSendWorkNoticeContext(ctx context.Context, notice WorkNotice) (int64, error) {
+	if notice.Message == nil {
+		return 0, errors.New("work notice has no message")
+	}
+	if len(notice.UserIDs) == 0 && len(notice.DeptIDs) == 0 && !notice.ToAllUser {
+		return 0, errors.New("work notice has no receivers")
+	}
+	if len(notice.UserIDs) > MaxWorkNoticeUsers {
+		return 0, errors.New("work notice can be sent to at most " + strconv.Itoa(MaxWorkNoticeUsers) + " users at once")
+	}
+	agentID, err := c.agentID(notice.AgentID)
+	if err != nil {
+		return 0, err
+	}
+
+	request := map[string]interface{}{
+		"agent_id": agentID,
+		"msg":      messagePayload(notice.Message),
+	}
+	if len(notice.UserIDs) > 0 {
+		request["userid_list"] = strings.Join(notice.UserIDs, ",")
+	}
+	if len(notice.DeptIDs) > 0 {
+		depts := make([]string, len(notice.DeptIDs))
+		for i, id := range notice.DeptIDs {
+			depts[i] = strconv.Itoa(id)
+		}
+		request["dept_id_list"] = strings.Join(depts, ",")
+	}
+	if notice.ToAllUser {
+		request["to_all_user"] = true
+	}
+
+	var data struct {
+		OAPIResponse
+		TaskID int64 `json:"task_id"`
+	}
+	err = c.httpRPC(ctx, "topapi/message/corpconversation/asyncsend_v2", nil, request, &data)
+	return data.TaskID, err
+}
+
+//GetWorkNoticeProgress is 获取异步发送工作通知消息的发送进度
+func (c *DingTalkClient) GetWorkNoticeProgress(agentID string, taskID int64) (*WorkNoticeProgress, error) {
+	return c.GetWorkNoticeProgressContext(context.Background(), agentID, taskID)
+}
+
+//GetWorkNoticeProgressContext is GetWorkNoticeProgress with a context
+func (c *DingTalkClient) GetWorkNoticeProgressContext(ctx context.Context, agentID string, taskID int64) (*WorkNoticeProgress, error) {
+	id, err := c.agentID(agentID)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		OAPIResponse
+		Progress WorkNoticeProgress `json:"progress"`
+	}
+	request := map[string]interface{}{
+		"agent_id": id,
+		"task_id":  taskID,
+	}
+	err = c.httpRPC(ctx, "topapi/message/corpconversation/getsendprogress", nil, request, &data)
+	return &data.Progress, err
+}
+
+//GetWorkNoticeResult is 获取异步发送工作通知消息的发送结果
+func (c *DingTalkClient) GetWorkNoticeResult(agentID string, taskID int64) (*WorkNoticeResult, error) {
+	return c.GetWorkNoticeResultContext(context.Background(), agentID, taskID)
+}
+
+//GetWorkNoticeResultContext is GetWorkNoticeResult with a context
+func (c *DingTalkClient) GetWorkNoticeResultContext(ctx context.Context, agentID string, taskID int64) (*WorkNoticeResult, error) {
+	id, err := c.agentID(agentID)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		OAPIResponse
+		SendResult WorkNoticeResult `json:"send_result"`
+	}
+	request := map[string]interface{}{
+		"agent_id": id,
+		"task_id":  taskID,
+	}
+	err = c.httpRPC(ctx, "topapi/message/corpconversation/getsendresult", nil, request, &data)
+	return &data.SendResult, err
+}
+
+//RecallWorkNotice is 撤回工作通知消息
+func (c *DingTalkClient) RecallWorkNotice(agentID string, taskID int64) error {
+	return c.RecallWorkNoticeContext(context.Background(), agentID, taskID)
+}
+
+//RecallWorkNoticeContext is RecallWorkNotice with a context
+func (c *DingTalkClient) RecallWorkNoticeContext(ctx context.Context, agentID string, taskID int64) error {
+	id, err := c.agentID(agentID)
+	if err != nil {
+		return err
+	}
+	var data OAPIResponse
+	request := map[string]interface{}{
+		"agent_id":    id,
+		"msg_task_id": taskID,
+	}
+	return c.httpRPC(ctx, "topapi/message/corpconversation/recall", nil, request, &data)
+}
+
+//UpdateWorkNoticeStatusBar is 更新工作通知OA消息的状态栏, for a notice sent with a status bar
+func (c *DingTalkClient) UpdateWorkNoticeStatusBar(agentID string, taskID int64, bar OAStatusBar) error {
+	return c.UpdateWorkNoticeStatusBarContext(context.Background(), agentID, taskID, bar)
+}
+
+//UpdateWorkNoticeStatusBarContext is UpdateWorkNoticeStatusBar with a context
+func (c *DingTalkClient) UpdateWorkNoticeStatusBarContext(ctx context.Context, agentID string, taskID int64, bar OAStatusBar) error {
+	if err := bar.validate(); err != nil {
+		return err
+	}
+	id, err := c.agentID(agentID)
+	if err != nil {
+		return err
+	}
+	var data OAPIResponse
+	request := map[string]interface{}{
+		"agent_id":     id,
+		"task_id":      taskID,
+		"status_value": bar.Value,
+	}
+	if bar.BgColor != "" {
+		request["status_bg"] = bar.BgColor
+	}
+	return c.httpRPC(ctx, "topapi/message/corpconversation/status_bar/update", nil, request, &data)
+}
@@ -0,0 +1,74 @@
+package godingtalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestAsyncSendWorkNotice(t *testing.T) {
+	var request map[string]interface{}
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/topapi/message/corpconversation/asyncsend_v2" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &request)
+		return jsonResponse(200, `{"errcode":0,"task_id":256271667526,"request_id":"4jzllmte0wau"}`), nil
+	})
+	client.AgentID = "22194403"
+
+	taskID, err := client.AsyncSendWorkNotice(WorkNotice{
+		UserIDs: []string{"u1", "u2"},
+		DeptIDs: []int{1, 2},
+		Message: TextMessage{Content: "hello"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taskID != 256271667526 {
+		t.Errorf("unexpected task id %d", taskID)
+	}
+	if request["agent_id"] != float64(22194403) || request["userid_list"] != "u1,u2" || request["dept_id_list"] != "1,2" {
+		t.Errorf("unexpected request %v", request)
+	}
+	if msg, _ := request["msg"].(map[string]interface{}); msg["msgtype"] != "text" {
+		t.Errorf("unexpected msg %v", request["msg"])
+	}
+
+	if _, err = client.AsyncSendWorkNotice(WorkNotice{Message: TextMessage{}}); err == nil {
+		t.Error("expected an error for a notice without receivers")
+	}
+	if _, err = client.AsyncSendWorkNotice(WorkNotice{UserIDs: make([]string, MaxWorkNoticeUsers+1), Message: TextMessage{}}); err == nil {
+		t.Error("expected an error for too many users")
+	}
+}
+
+func TestWorkNoticeProgressAndResult(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/topapi/message/corpconversation/getsendprogress":
+			return jsonResponse(200, `{"errcode":0,"progress":{"progress_in_percent":100,"status":2}}`), nil
+		case "/topapi/message/corpconversation/getsendresult":
+			return jsonResponse(200, `{"errcode":0,"send_result":{"read_user_id_list":["u1"],"unread_user_id_list":["u2"],"forbidden_user_id_list":["u3"]}}`), nil
+		default:
+			return jsonResponse(200, `{"errcode":0}`), nil
+		}
+	})
+
+	progress, err := client.GetWorkNoticeProgress("1", 42)
+	if err != nil || progress.Percent != 100 || progress.Status != WorkNoticeStatusDone {
+		t.Errorf("unexpected progress %+v %v", progress, err)
+	}
+	result, err := client.GetWorkNoticeResult("1", 42)
+	if err != nil || len(result.ReadUserIDs) != 1 || result.UnreadUserIDs[0] != "u2" || result.ForbiddenUserIDs[0] != "u3" {
+		t.Errorf("unexpected result %+v %v", result, err)
+	}
+	if err = client.RecallWorkNotice("1", 42); err != nil {
+		t.Error(err)
+	}
+	if err = client.RecallWorkNotice("", 42); err == nil {
+		t.Error("expected an error without agent id")
+	}
+}
@@ -0,0 +1,278 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//DefaultCallbackMaxAge is how old the timestamp of an event callback can be
+const DefaultCallbackMaxAge = 5 * time.Minute
+
+//MaxCallbackBodySize is the largest body read by the callback handlers, well above
+//the size of any DingTalk event
+const MaxCallbackBodySize = 256 << 10
+
+//CallbackEvent is an event posted to the callback URL registered by RegisterCallback
+type CallbackEvent struct {
+	EventType string
+	Data      json.RawMessage // 解密后的事件内容
+}
+
+//Decode unmarshals the content of the event into v
+func (e *CallbackEvent) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+//EventHandler handles the events received by a CallbackHandler. An error makes
+//DingTalk retry the event later
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event *CallbackEvent) error
+}
+
+//EventHandlerFunc adapts an ordinary function to the EventHandler interface
+type EventHandlerFunc func(ctx context.Context, event *CallbackEvent) error
+
+//HandleEvent calls f(ctx, event)
+func (f EventHandlerFunc) HandleEvent(ctx context.Context, event *CallbackEvent) error {
+	return f(ctx, event)
+}
+
+//CallbackHandler is an http.Handler for the callback URL registered by RegisterCallback.
+//It checks the signature of each event, decrypts it with Crypto, answers the
+//check_url probe and replies with the encrypted "success" once Handler is done.
+//With a Store, events which DingTalk retries or replays reach Handler at most once
+type CallbackHandler struct {
+	Crypto   *Crypto
+	Handler  EventHandler
+	MaxAge   time.Duration // 为0时使用 DefaultCallbackMaxAge
+	Store    EventStore
+	StoreTTL time.Duration // 为0时使用 DefaultEventStoreTTL
+	Logger   Logger
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+//NewCallbackHandler creates a CallbackHandler decrypting events with crypto, made
+//from the token and aes_key given to RegisterCallback
+func NewCallbackHandler(crypto *Crypto, handler EventHandler) *CallbackHandler {
+	return &CallbackHandler{
+		Crypto:  crypto,
+		Handler: handler,
+	}
+}
+
+//Errors rejecting an event callback
+var (
+	errCallbackStale    = errors.New("callback timestamp is out of range")
+	errCallbackReplayed = errors.New("callback nonce has already been used")
+)
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	signature, timestamp, nonce := query.Get("signature"), query.Get("timestamp"), query.Get("nonce")
+	now := time.Now()
+	if err := h.checkTimestamp(timestamp, now); err != nil {
+		h.reject(w, r, err)
+		return
+	}
+
+	var body struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if !decodeCallbackBody(w, r, &body, "invalid callback") {
+		return
+	}
+	plain, err := h.Crypto.DecryptMsg(signature, timestamp, nonce, body.Encrypt)
+	if err != nil {
+		h.reject(w, r, err)
+		return
+	}
+	if !h.claimNonce(nonce, now) {
+		h.reject(w, r, errCallbackReplayed)
+		return
+	}
+
+	event := &CallbackEvent{Data: json.RawMessage(plain)}
+	var head struct {
+		EventType string `json:"EventType"`
+	}
+	if err = json.Unmarshal(event.Data, &head); err != nil {
+		http.Error(w, "invalid callback event", http.StatusBadRequest)
+		return
+	}
+	event.EventType = head.EventType
+
+	if err = h.dispatch(ctx, event); err != nil {
+		// let DingTalk's retry through, should it reuse the nonce
+		h.releaseNonce(nonce)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.writeSuccess(w)
+}
+
+//dispatch passes event to Handler, unless Store has seen it already, as it does for
+//pushed and replayed events alike
+func (h *CallbackHandler) dispatch(ctx context.Context, event *CallbackEvent) error {
+	if event.EventType == EVENT_CHECK_URL || h.Handler == nil {
+		return nil
+	}
+	var key string
+	if h.Store != nil {
+		key = eventKey(event)
+		claimed, err := h.Store.Claim(key, h.storeTTL())
+		if err != nil {
+			h.log(ctx, slog.LevelError, "dingtalk event store failed", "event_type", event.EventType, "error", err)
+			return err
+		}
+		if !claimed {
+			h.log(ctx, slog.LevelDebug, "dingtalk event already handled", "event_type", event.EventType, "key", key)
+			return nil
+		}
+	}
+	err := h.Handler.HandleEvent(ctx, event)
+	if err != nil {
+		h.log(ctx, slog.LevelError, "dingtalk event handler failed", "event_type", event.EventType, "error", err)
+		if h.Store != nil {
+			h.Store.Release(key)
+		}
+	}
+	return err
+}
+
+//ReplayFailed fetches the events DingTalk could not push, e.g. while the callback URL
+//was down, and passes them to Handler as if they had been pushed. It returns how
+//many events were handled. As DingTalk returns each event only once, events whose
+//handler fails, or which cannot be converted, are lost unless Handler keeps them,
+//and only reported in the error
+func (h *CallbackHandler) ReplayFailed(ctx context.Context, client *DingTalkClient) (int, error) {
+	var errs []error
+	handled := 0
+	for {
+		events, hasMore, err := client.GetFailedCallbacksContext(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, event := range events {
+			if handlerErr := h.dispatch(ctx, event); handlerErr != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", event.EventType, handlerErr))
+				continue
+			}
+			handled++
+		}
+		if !hasMore || (len(events) == 0 && err == nil) {
+			return handled, errors.Join(errs...)
+		}
+	}
+}
+
+//checkTimestamp checks that the timestamp in milliseconds is within MaxAge of now
+func (h *CallbackHandler) checkTimestamp(timestamp string, now time.Time) error {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errCallbackStale
+	}
+	sent := time.Unix(0, ms*int64(time.Millisecond))
+	if now.Sub(sent) > h.maxAge() || sent.Sub(now) > h.maxAge() {
+		return errCallbackStale
+	}
+	return nil
+}
+
+//claimNonce records nonce, and reports false if it was already used within MaxAge.
+//Older nonces are forgotten, as their timestamps can no longer pass checkTimestamp
+func (h *CallbackHandler) claimNonce(nonce string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.nonces == nil {
+		h.nonces = map[string]time.Time{}
+	}
+	for n, seen := range h.nonces {
+		if now.Sub(seen) > 2*h.maxAge() {
+			delete(h.nonces, n)
+		}
+	}
+	if _, ok := h.nonces[nonce]; ok {
+		return false
+	}
+	h.nonces[nonce] = now
+	return true
+}
+
+func (h *CallbackHandler) releaseNonce(nonce string) {
+	h.mu.Lock()
+	delete(h.nonces, nonce)
+	h.mu.Unlock()
+}
+
+//writeSuccess replies with the encrypted "success" which tells DingTalk the event was received
+func (h *CallbackHandler) writeSuccess(w http.ResponseWriter) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	nonce := h.Crypto.RandomString(8)
+	encrypt, signature, err := h.Crypto.EncryptMsg("success", timestamp, nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", typeJSON+"; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{
+		"msg_signature": signature,
+		"timeStamp":     timestamp,
+		"nonce":         nonce,
+		"encrypt":       encrypt,
+	})
+}
+
+//decodeCallbackBody decodes the body of r, up to MaxCallbackBodySize, into v. It
+//answers the request and returns false when the body is too large or invalid
+func decodeCallbackBody(w http.ResponseWriter, r *http.Request, v interface{}, invalid string) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxCallbackBodySize)).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "callback too large", http.StatusRequestEntityTooLarge)
+	} else {
+		http.Error(w, invalid, http.StatusBadRequest)
+	}
+	return false
+}
+
+func (h *CallbackHandler) reject(w http.ResponseWriter, r *http.Request, err error) {
+	h.log(r.Context(), slog.LevelWarn, "dingtalk callback rejected", "error", err)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+func (h *CallbackHandler) storeTTL() time.Duration {
+	if h.StoreTTL > 0 {
+		return h.StoreTTL
+	}
+	return DefaultEventStoreTTL
+}
+
+func (h *CallbackHandler) maxAge() time.Duration {
+	if h.MaxAge > 0 {
+		return h.MaxAge
+	}
+	return DefaultCallbackMaxAge
+}
+
+func (h *CallbackHandler) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Log(ctx, level, msg, args...)
+	}
+}
@@ -0,0 +1,170 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAESKey = "1234567890123456789012345678901234567890aes"
+
+//encryptedCallback builds the request DingTalk posts for the event
+func encryptedCallback(t *testing.T, crypto *Crypto, event string, sent time.Time, nonce string) *http.Request {
+	timestamp := strconv.FormatInt(sent.UnixNano()/int64(time.Millisecond), 10)
+	encrypt, signature, err := crypto.EncryptMsg(event, timestamp, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := url.Values{"signature": {signature}, "timestamp": {timestamp}, "nonce": {nonce}}
+	body, _ := json.Marshal(map[string]string{"encrypt": encrypt})
+	return httptest.NewRequest("POST", "/callback?"+query.Encode(), strings.NewReader(string(body)))
+}
+
+//decryptReply checks the encrypted reply of a CallbackHandler and returns its content
+func decryptReply(t *testing.T, crypto *Crypto, w *httptest.ResponseRecorder) string {
+	var reply map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("unexpected reply %s", w.Body)
+	}
+	plain, err := crypto.DecryptMsg(reply["msg_signature"], reply["timeStamp"], reply["nonce"], reply["encrypt"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+func TestCallbackHandler(t *testing.T) {
+	crypto := NewCrypto("token", testAESKey, "corp")
+	var events []*CallbackEvent
+	h := NewCallbackHandler(crypto, EventHandlerFunc(func(ctx context.Context, event *CallbackEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, encryptedCallback(t, crypto, `{"EventType":"check_url"}`, time.Now(), "n1"))
+	if w.Code != 200 || decryptReply(t, crypto, w) != "success" {
+		t.Fatalf("check_url failed: %d %s", w.Code, w.Body)
+	}
+	if len(events) != 0 {
+		t.Error("check_url must not reach the handler")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, encryptedCallback(t, crypto, `{"EventType":"user_add_org","UserId":["u1"],"CorpId":"corp"}`, time.Now(), "n2"))
+	if w.Code != 200 || decryptReply(t, crypto, w) != "success" {
+		t.Fatalf("event failed: %d %s", w.Code, w.Body)
+	}
+	var contact ContactEvent
+	if len(events) != 1 || events[0].EventType != EVENT_USER_ADD_ORG || events[0].Decode(&contact) != nil || contact.UserIDs[0] != "u1" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestCallbackHandlerRejects(t *testing.T) {
+	crypto := NewCrypto("token", testAESKey, "corp")
+	called := 0
+	h := NewCallbackHandler(crypto, EventHandlerFunc(func(ctx context.Context, event *CallbackEvent) error {
+		called++
+		return nil
+	}))
+	event := `{"EventType":"user_add_org"}`
+
+	replayed := encryptedCallback(t, crypto, event, time.Now(), "same")
+	h.ServeHTTP(httptest.NewRecorder(), replayed)
+	tampered := encryptedCallback(t, crypto, event, time.Now(), "other")
+	tampered.URL.RawQuery = strings.Replace(tampered.URL.RawQuery, "nonce=other", "nonce=changed", 1)
+
+	cases := map[string]*http.Request{
+		"replayed":  encryptedCallback(t, crypto, event, time.Now(), "same"),
+		"stale":     encryptedCallback(t, crypto, event, time.Now().Add(-time.Hour), "n3"),
+		"tampered":  tampered,
+		"wrong key": encryptedCallback(t, NewCrypto("other", testAESKey, "corp"), event, time.Now(), "n4"),
+	}
+	for name, req := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+	}
+	if called != 1 {
+		t.Errorf("expected only the first event to be handled, got %d", called)
+	}
+}
+
+func TestCallbackHandlerTooLarge(t *testing.T) {
+	crypto := NewCrypto("token", testAESKey, "corp")
+	h := NewCallbackHandler(crypto, nil)
+	req := encryptedCallback(t, crypto, `{"EventType":"user_add_org"}`, time.Now(), "n1")
+	req.Body = io.NopCloser(strings.NewReader(`{"encrypt":"` + strings.Repeat("a", MaxCallbackBodySize) + `"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+}
+
+func TestCallbackHandlerError(t *testing.T) {
+	crypto := NewCrypto("token", testAESKey, "corp")
+	fail := true
+	h := NewCallbackHandler(crypto, EventHandlerFunc(func(ctx context.Context, event *CallbackEvent) error {
+		if fail {
+			return errors.New("database down")
+		}
+		return nil
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, encryptedCallback(t, crypto, `{"EventType":"user_add_org"}`, time.Now(), "n1"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 so that DingTalk retries, got %d", w.Code)
+	}
+	fail = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, encryptedCallback(t, crypto, `{"EventType":"user_add_org"}`, time.Now(), "n1"))
+	if w.Code != 200 {
+		t.Errorf("a retry after a failure must be accepted, got %d", w.Code)
+	}
+}
+
+func TestCallbackHandlerReplayFailed(t *testing.T) {
+	pages := []string{
+		`{"errcode":0,"has_more":true,"failed_list":[{"call_back_tag":"user_add_org","userid":["u1"]},{"call_back_tag":"user_leave_org","userid":["u2"]},{"call_back_tag":null}]}`,
+		`{"errcode":0,"has_more":false,"failed_list":[{"call_back_tag":"user_add_org","userid":["u3"]}]}`,
+	}
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		page := pages[0]
+		pages = pages[1:]
+		return jsonResponse(200, page), nil
+	})
+
+	var added []string
+	router := NewEventRouter()
+	router.OnUserAddOrg(func(ctx context.Context, event *ContactEvent) error {
+		added = append(added, event.UserIDs...)
+		return nil
+	})
+	router.OnUserLeaveOrg(func(ctx context.Context, event *ContactEvent) error {
+		return errors.New("directory unavailable")
+	})
+	h := NewCallbackHandler(NewCrypto("token", testAESKey, "corp"), router)
+
+	handled, err := h.ReplayFailed(context.Background(), client)
+	if handled != 2 || len(added) != 2 || added[0] != "u1" || added[1] != "u3" {
+		t.Errorf("unexpected replay %d %v", handled, added)
+	}
+	if err == nil || !strings.Contains(err.Error(), "user_leave_org: directory unavailable") {
+		t.Errorf("expected the failed event in the error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"call_back_tag":null`) {
+		t.Errorf("expected the bad item in the error, got %v", err)
+	}
+}
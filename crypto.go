@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
@@ -33,24 +34,33 @@ type Crypto struct {
 	suiteKey	一般使用corpID
 */
 func NewCrypto(token, aesKey, suiteKey string) (c *Crypto) {
-	c = &Crypto{
+	c, err := NewCryptoE(token, aesKey, suiteKey)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// NewCryptoE 同 NewCrypto, 但 aesKey 不合法时返回错误而不是 panic
+func NewCryptoE(token, aesKey, suiteKey string) (*Crypto, error) {
+	c := &Crypto{
 		Token:    token,
 		AesKey:   aesKey,
 		SuiteKey: suiteKey,
 	}
 	if len(c.AesKey) != AES_ENCODE_KEY_LENGTH {
-		panic("不合法的aeskey")
+		return nil, errors.New("不合法的aeskey")
 	}
 	var err error
 	c.bkey, err = base64.StdEncoding.DecodeString(aesKey + "=")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	c.block, err = aes.NewCipher(c.bkey)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-	return c
+	return c, nil
 }
 
 /*
@@ -71,12 +81,26 @@ func (c *Crypto) DecryptMsg(signature, timeStamp, nonce, secretStr string) (stri
 	if len(decode) < aes.BlockSize {
 		return "", errors.New("密文太短啦")
 	}
+	if len(decode)%aes.BlockSize != 0 {
+		return "", errors.New("密文大小不为16的倍数")
+	}
 	blockMode := cipher.NewCBCDecrypter(c.block, c.bkey[:c.block.BlockSize()])
 	plantText := make([]byte, len(decode))
 	blockMode.CryptBlocks(plantText, decode)
-	plantText = PKCS7UnPadding(plantText)
+	// 钉钉按32字节填充, 本包按16字节填充
+	plantText, err = pkcs7UnPadding(plantText, 32)
+	if err != nil {
+		return "", err
+	}
+	// 16字节随机串 + 4字节消息长度 + 消息 + SuiteKey
+	if len(plantText) < 16+4 {
+		return "", errors.New("明文太短啦")
+	}
 	size := binary.BigEndian.Uint32(plantText[16 : 16+4])
 	plantText = plantText[16+4:]
+	if uint64(size) > uint64(len(plantText)) {
+		return "", errors.New("消息长度不正确")
+	}
 	cropid := plantText[size:]
 	if string(cropid) != c.SuiteKey {
 		return "", errors.New("CropID不正确")
@@ -84,10 +108,31 @@ func (c *Crypto) DecryptMsg(signature, timeStamp, nonce, secretStr string) (stri
 	return string(plantText[:size]), nil
 }
 
+// PKCS7UnPadding 去掉填充, 填充不合法时原样返回
 func PKCS7UnPadding(plantText []byte) []byte {
+	unpadded, err := pkcs7UnPadding(plantText, 256)
+	if err != nil {
+		return plantText
+	}
+	return unpadded
+}
+
+// pkcs7UnPadding 去掉填充, 并校验填充长度和每个填充字节
+func pkcs7UnPadding(plantText []byte, blockSize int) ([]byte, error) {
 	length := len(plantText)
+	if length == 0 {
+		return nil, errors.New("填充不正确")
+	}
 	unpadding := int(plantText[length-1])
-	return plantText[:(length - unpadding)]
+	if unpadding == 0 || unpadding > blockSize || unpadding > length {
+		return nil, errors.New("填充不正确")
+	}
+	for _, b := range plantText[length-unpadding:] {
+		if int(b) != unpadding {
+			return nil, errors.New("填充不正确")
+		}
+	}
+	return plantText[:(length - unpadding)], nil
 }
 
 /*
@@ -134,7 +179,8 @@ func (c *Crypto) GenerateSignature(token, timeStamp, nonce, secretStr string) st
 
 // 校验数据签名
 func (c *Crypto) VerifySignature(token, timeStamp, nonce, secretStr, sigture string) bool {
-	return c.GenerateSignature(token, timeStamp, nonce, secretStr) == sigture
+	expected := c.GenerateSignature(token, timeStamp, nonce, secretStr)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(sigture)) == 1
 }
 
 func (c *Crypto) RandomString(n int, alphabets ...byte) string {
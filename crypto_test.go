@@ -0,0 +1,99 @@
+package godingtalk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestNewCryptoE(t *testing.T) {
+	if _, err := NewCryptoE("token", testAESKey, "corp"); err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]string{
+		"short":  testAESKey[1:],
+		"base64": "!" + testAESKey[1:],
+	} {
+		if c, err := NewCryptoE("token", key, "corp"); err == nil || c != nil {
+			t.Errorf("%s key should be rejected", name)
+		}
+	}
+}
+
+func TestCryptoRoundTrip(t *testing.T) {
+	c := NewCrypto("token", testAESKey, "corp")
+	for _, msg := range []string{"", "success", string(bytes.Repeat([]byte("钉"), 100))} {
+		encrypt, signature, err := c.EncryptMsg(msg, "1577262236757", "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := c.DecryptMsg(signature, "1577262236757", "nonce", encrypt)
+		if err != nil || plain != msg {
+			t.Errorf("round trip of %q: %q %v", msg, plain, err)
+		}
+		if _, err = c.DecryptMsg(signature, "1577262236758", "nonce", encrypt); err == nil {
+			t.Error("signature should not match another timestamp")
+		}
+	}
+}
+
+func TestCryptoDecryptMalformed(t *testing.T) {
+	c := NewCrypto("token", testAESKey, "corp")
+	for name, cipherText := range map[string][]byte{
+		"not a block": bytes.Repeat([]byte{1}, 17),
+		"one block":   bytes.Repeat([]byte{1}, 16),
+		"four blocks": bytes.Repeat([]byte{0xff}, 64),
+	} {
+		encrypt := base64.StdEncoding.EncodeToString(cipherText)
+		signature := c.GenerateSignature(c.Token, "1", "n", encrypt)
+		if _, err := c.DecryptMsg(signature, "1", "n", encrypt); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPKCS7UnPadding(t *testing.T) {
+	for _, tt := range []struct {
+		in   []byte
+		want []byte
+		ok   bool
+	}{
+		{[]byte("abc\x02\x02"), []byte("abc"), true},
+		{[]byte("abc\x01\x02"), nil, false},
+		{[]byte("abc\x00"), nil, false},
+		{[]byte("\x05"), nil, false},
+		{nil, nil, false},
+	} {
+		got, err := pkcs7UnPadding(tt.in, 16)
+		if (err == nil) != tt.ok || !bytes.Equal(got, tt.want) {
+			t.Errorf("pkcs7UnPadding(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+	if got := PKCS7UnPadding([]byte("\x05")); !bytes.Equal(got, []byte("\x05")) {
+		t.Errorf("invalid padding should be kept, got %q", got)
+	}
+}
+
+func FuzzDecryptMsg(f *testing.F) {
+	c := NewCrypto("token", testAESKey, "corp")
+	encrypt, _, _ := c.EncryptMsg("success", "1", "n")
+	cipherText, _ := base64.StdEncoding.DecodeString(encrypt)
+	f.Add(cipherText)
+	f.Add(bytes.Repeat([]byte{0}, 32))
+	f.Fuzz(func(t *testing.T, cipherText []byte) {
+		encrypt := base64.StdEncoding.EncodeToString(cipherText)
+		signature := c.GenerateSignature(c.Token, "1", "n", encrypt)
+		c.DecryptMsg(signature, "1", "n", encrypt)
+	})
+}
+
+func FuzzPKCS7UnPadding(f *testing.F) {
+	f.Add([]byte("abc\x02\x02"))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, plantText []byte) {
+		PKCS7UnPadding(plantText)
+		if got, err := pkcs7UnPadding(plantText, 32); err == nil && len(got) >= len(plantText) {
+			t.Errorf("padding of %q was not removed", plantText)
+		}
+	})
+}
@@ -50,7 +50,6 @@ func fatalError(err error) {
 
 func main() {
 	c := dingtalk.NewDingTalkClient(os.Getenv("corpid"), os.Getenv("corpsecret"))
-	c.RefreshAccessToken()
 	var err error
 	if len(os.Args) < 2 {
 		usage()
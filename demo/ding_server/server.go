@@ -22,7 +22,6 @@ var client *godingtalk.DingTalkClient
 func getUserInfo(w http.ResponseWriter, r *http.Request) {
 	code := r.FormValue("code")
 
-	client.RefreshAccessToken()
 	info, _ := client.UserInfoByCode(code)
 
 	json.NewEncoder(w).Encode(info)
@@ -38,7 +37,6 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 		ErrMsg  string `json:"errmsg"`
 	}
 
-	client.RefreshAccessToken()
 	err := client.SendTextMessage(sender, chatid, content)
 	if err != nil {
 		resp.ErrCode = -1
@@ -55,8 +53,12 @@ func serveTemplate(w http.ResponseWriter, r *http.Request) {
 	url := "http://" + r.Host + r.RequestURI
 	timestamp := fmt.Sprintf("%d", time.Now().Unix())
 
-	client.RefreshAccessToken()
-	configString := client.GetConfig("abcdabc", timestamp, url)
+	config, err := client.GetConfig("abcdabc", timestamp, url)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	configString, _ := json.Marshal(config)
 
 	data := make(map[string]interface{})
 	data["config"] = template.JS(configString)
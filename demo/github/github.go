@@ -2,7 +2,6 @@ package github
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -48,7 +47,7 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 		Context: context,
 		AllowInvalidServerCertificate: true,
 	}
-	c.Cache = NewMemCache(context, ".access_token")
+	c.Cache = NewMemCache(context, "godingtalk:")
 	refresh_token_error := c.RefreshAccessToken()
 
 	msg := godingtalk.OAMessage{}
@@ -123,22 +122,22 @@ func verifySignature(secret []byte, signature string, body []byte) bool {
 }
 
 type MemCache struct {
-	ctx appengine.Context
-	key string
+	ctx    appengine.Context
+	prefix string
 }
 
-func NewMemCache(ctx appengine.Context, key string) *MemCache {
+func NewMemCache(ctx appengine.Context, prefix string) *MemCache {
 	return &MemCache{
-		ctx: ctx,
-		key: key,
+		ctx:    ctx,
+		prefix: prefix,
 	}
 }
 
-func (c *MemCache) Set(data godingtalk.Expirable) error {
+func (c *MemCache) Set(key string, data godingtalk.Expirable) error {
 	bytes, err := json.Marshal(data)
 	if err == nil {
 		item := &memcache.Item{
-			Key:        c.key,
+			Key:        c.prefix + key,
 			Value:      bytes,
 			Expiration: time.Duration(data.ExpiresIn()) * time.Second,
 		}
@@ -147,15 +146,18 @@ func (c *MemCache) Set(data godingtalk.Expirable) error {
 	return err
 }
 
-func (c *MemCache) Get(data godingtalk.Expirable) error {
-	item, err := memcache.Get(c.ctx, c.key)
+func (c *MemCache) Get(key string, data godingtalk.Expirable) error {
+	item, err := memcache.Get(c.ctx, c.prefix+key)
+	if err == memcache.ErrCacheMiss {
+		return godingtalk.ErrCacheMiss
+	}
 	if err == nil {
 		err = json.Unmarshal(item.Value, data)
 		if err == nil {
 			created := data.CreatedAt()
 			expires := data.ExpiresIn()
 			if err == nil && time.Now().Unix() > created+int64(expires-60) {
-				err = errors.New("Data is already expired")
+				err = godingtalk.ErrCacheExpired
 			}
 		}
 	}
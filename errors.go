@@ -0,0 +1,115 @@
+package godingtalk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//DingTalk errcodes which callers commonly need to tell apart
+const (
+	ErrCodeSystemBusy         = -1    // 系统繁忙
+	ErrCodeRateLimited        = 88    // 调用频率超限
+	ErrCodeInvalidAccessToken = 40014 // 不合法的access_token
+	ErrCodeAccessTokenExpired = 42001 // access_token超时
+	ErrCodeUserNotFound       = 60121 // 找不到该用户
+	ErrCodeQPSLimited         = 90018 // 当前企业调用该接口的QPS超过上限
+)
+
+//Taobao gateway error codes
+const (
+	TaobaoCodeAppCallLimited = 7  // App Call Limited
+	TaobaoCodeMissingSession = 26 // Missing Session
+	TaobaoCodeInvalidSession = 27 // Invalid Session
+)
+
+//APIError is returned when DingTalk Open API answers with a non-zero errcode
+type APIError struct {
+	Path      string
+	ErrCode   int
+	ErrMsg    string
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d: %s", e.ErrCode, e.ErrMsg)
+}
+
+//TaobaoAPIError is returned when the Taobao gateway answers with an error_response
+type TaobaoAPIError struct {
+	Method    string
+	Code      int
+	Msg       string
+	SubCode   string
+	SubMsg    string
+	RequestID string
+}
+
+func (e *TaobaoAPIError) Error() string {
+	return fmt.Sprintf("code: %d, msg: %s, sub_code: %s, sub_msg: %s", e.Code, e.Msg, e.SubCode, e.SubMsg)
+}
+
+//HTTPStatusError is returned when DingTalk answers with a non-200 HTTP status
+type HTTPStatusError struct {
+	Path       string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "Server error: " + e.Status
+}
+
+//IsErrCode reports whether err is an APIError with the given errcode
+func IsErrCode(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.ErrCode == code
+}
+
+//IsTokenExpired reports whether err means the access token is invalid or expired
+func IsTokenExpired(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrCode == ErrCodeInvalidAccessToken || apiErr.ErrCode == ErrCodeAccessTokenExpired
+	}
+	var taobaoErr *TaobaoAPIError
+	if errors.As(err, &taobaoErr) {
+		return taobaoErr.Code == TaobaoCodeMissingSession || taobaoErr.Code == TaobaoCodeInvalidSession
+	}
+	return false
+}
+
+//IsRateLimited reports whether err means the call was rejected by DingTalk's rate limits
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrCode == ErrCodeRateLimited || apiErr.ErrCode == ErrCodeQPSLimited
+	}
+	var taobaoErr *TaobaoAPIError
+	if errors.As(err, &taobaoErr) {
+		return taobaoErr.Code == TaobaoCodeAppCallLimited
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+//IsUserNotFound reports whether err means the requested user does not exist
+func IsUserNotFound(err error) bool {
+	return IsErrCode(err, ErrCodeUserNotFound)
+}
+
+//withPath records which API path produced err
+func withPath(err error, path string) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Path == "" {
+		apiErr.Path = path
+	}
+	var taobaoErr *TaobaoAPIError
+	if errors.As(err, &taobaoErr) && taobaoErr.Method == "" {
+		taobaoErr.Method = path
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package godingtalk
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{typeJSON}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func newStubClient(fn roundTripFunc) *DingTalkClient {
+	return &DingTalkClient{
+		AccessToken: "token",
+		RWMutex:     &sync.RWMutex{},
+		HTTPClient:  &http.Client{Transport: fn},
+	}
+}
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(200, `{"errcode":60121,"errmsg":"找不到该用户","request_id":"abc"}`), nil
+	})
+	_, err := client.UserDetail("nobody")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T %v", err, err)
+	}
+	if apiErr.Path != "user/get" || apiErr.ErrCode != 60121 || apiErr.RequestID != "abc" {
+		t.Errorf("unexpected error fields: %+v", apiErr)
+	}
+	if !IsUserNotFound(err) || IsTokenExpired(err) || IsRateLimited(err) {
+		t.Errorf("helpers misclassified %v", err)
+	}
+	if err.Error() != "60121: 找不到该用户" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestHTTPStatusError(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(429, ``), nil
+	})
+	_, err := client.DepartmentList()
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 429 || statusErr.Path != "department/list" {
+		t.Fatalf("expected *HTTPStatusError, got %T %v", err, err)
+	}
+	if !IsRateLimited(err) {
+		t.Error("429 should be rate limited")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	cases := []struct {
+		err         error
+		expired     bool
+		rateLimited bool
+	}{
+		{&APIError{ErrCode: ErrCodeInvalidAccessToken}, true, false},
+		{&APIError{ErrCode: ErrCodeAccessTokenExpired}, true, false},
+		{&APIError{ErrCode: ErrCodeRateLimited}, false, true},
+		{fmt.Errorf("wrapped: %w", &APIError{ErrCode: ErrCodeQPSLimited}), false, true},
+		{&TaobaoAPIError{Code: TaobaoCodeInvalidSession}, true, false},
+		{&TaobaoAPIError{Code: TaobaoCodeAppCallLimited}, false, true},
+		{&HTTPStatusError{StatusCode: 502}, false, false},
+		{errors.New("other"), false, false},
+		{nil, false, false},
+	}
+	for _, tc := range cases {
+		if IsTokenExpired(tc.err) != tc.expired {
+			t.Errorf("IsTokenExpired(%v) != %v", tc.err, tc.expired)
+		}
+		if IsRateLimited(tc.err) != tc.rateLimited {
+			t.Errorf("IsRateLimited(%v) != %v", tc.err, tc.rateLimited)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package godingtalk
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultEventStoreTTL is how long a CallbackHandler remembers the events it handled
+const DefaultEventStoreTTL = 24 * time.Hour
+
+//EventStore remembers the callback events which have been handled, so that events
+//retried or replayed by DingTalk reach the handler at most once
+type EventStore interface {
+	// Claim records key for ttl, and reports false if it is already recorded
+	Claim(key string, ttl time.Duration) (bool, error)
+	// Release forgets key, so that the event can be handled again
+	Release(key string) error
+}
+
+//eventKeyFields are the fields which, with its type, identify an event
+var eventKeyFields = []string{
+	"TimeStamp", "CorpId", "corpId", "UserId", "DeptId", "ChatId", "StaffId", "staffId",
+	"processInstanceId", "taskId", "type", "result", "createTime", "finishTime",
+}
+
+//eventKey identifies event by its type, timestamp and ids, or by its whole content
+//when it has none of them
+func eventKey(event *CallbackEvent) string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(event.Data, &fields) != nil {
+		return event.EventType + "|" + sha1Sign(string(event.Data))
+	}
+	parts := []string{event.EventType}
+	for _, name := range eventKeyFields {
+		if v, ok := fields[name]; ok {
+			parts = append(parts, name+"="+canonicalJSON(v))
+		}
+	}
+	if dataList, ok := fields["DataList"]; ok {
+		var records []struct {
+			BizID string `json:"bizId"`
+		}
+		json.Unmarshal(dataList, &records)
+		ids := make([]string, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.BizID)
+		}
+		sort.Strings(ids)
+		parts = append(parts, "bizId="+strings.Join(ids, ","))
+	}
+	if len(parts) == 1 {
+		parts = append(parts, sha1Sign(string(event.Data)))
+	}
+	return strings.Join(parts, "|")
+}
+
+//canonicalJSON re-encodes v, so that values pushed as DingTalk sent them and values
+//re-encoded by failedCallbackEvent compare equal whatever their spacing and escaping
+func canonicalJSON(v json.RawMessage) string {
+	decoder := json.NewDecoder(bytes.NewReader(v))
+	decoder.UseNumber()
+	var value interface{}
+	if decoder.Decode(&value) != nil {
+		return string(v)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if encoder.Encode(value) != nil {
+		return string(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+//MemoryEventStore is an EventStore keeping up to a number of the most recent events in memory
+type MemoryEventStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently claimed first
+	entries  map[string]*list.Element
+}
+
+type eventStoreEntry struct {
+	key     string
+	expires time.Time
+}
+
+//NewMemoryEventStore creates a MemoryEventStore which forgets the least recently
+//claimed events beyond capacity
+func NewMemoryEventStore(capacity int) *MemoryEventStore {
+	return &MemoryEventStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (s *MemoryEventStore) Claim(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*eventStoreEntry)
+		s.order.MoveToFront(el)
+		if now.Before(entry.expires) {
+			return false, nil
+		}
+		entry.expires = now.Add(ttl)
+		return true, nil
+	}
+	s.entries[key] = s.order.PushFront(&eventStoreEntry{key: key, expires: now.Add(ttl)})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*eventStoreEntry).key)
+	}
+	return true, nil
+}
+
+func (s *MemoryEventStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.order.Remove(el)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+//FileEventStore is an EventStore kept in a single file, so that it survives restarts.
+//It is meant for a single process; use a shared store for several instances
+type FileEventStore struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+//NewFileEventStore creates a FileEventStore in the temporary directory, as NewFileCache does
+func NewFileEventStore(filename string) *FileEventStore {
+	return &FileEventStore{
+		Path: path.Join(os.TempDir(), filename),
+	}
+}
+
+func (s *FileEventStore) Claim(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	for k, expires := range s.entries {
+		if !now.Before(expires) {
+			delete(s.entries, k)
+		}
+	}
+	if _, ok := s.entries[key]; ok {
+		return false, nil
+	}
+	s.entries[key] = now.Add(ttl)
+	if err := s.saveLocked(); err != nil {
+		delete(s.entries, key)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileEventStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.entries[key]; !ok {
+		return nil
+	}
+	delete(s.entries, key)
+	return s.saveLocked()
+}
+
+func (s *FileEventStore) loadLocked() error {
+	if s.entries != nil {
+		return nil
+	}
+	entries := map[string]time.Time{}
+	bytes, err := ioutil.ReadFile(s.Path)
+	if err == nil {
+		err = json.Unmarshal(bytes, &entries)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+func (s *FileEventStore) saveLocked() error {
+	bytes, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, bytes)
+}
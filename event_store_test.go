@@ -0,0 +1,127 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testEventStore(t *testing.T, store EventStore) {
+	if ok, err := store.Claim("a", time.Minute); !ok || err != nil {
+		t.Fatalf("first claim should succeed: %v %v", ok, err)
+	}
+	if ok, _ := store.Claim("a", time.Minute); ok {
+		t.Error("second claim should fail")
+	}
+	if err := store.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Claim("a", time.Minute); !ok {
+		t.Error("claim after release should succeed")
+	}
+	store.Claim("short", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ok, _ := store.Claim("short", time.Minute); !ok {
+		t.Error("claim after expiry should succeed")
+	}
+}
+
+func TestMemoryEventStore(t *testing.T) {
+	testEventStore(t, NewMemoryEventStore(10))
+
+	store := NewMemoryEventStore(2)
+	store.Claim("1", time.Minute)
+	store.Claim("2", time.Minute)
+	store.Claim("1", time.Minute)
+	store.Claim("3", time.Minute)
+	if ok, _ := store.Claim("2", time.Minute); !ok {
+		t.Error("the least recently claimed key should have been evicted")
+	}
+	if ok, _ := store.Claim("3", time.Minute); ok {
+		t.Error("a recent key should be kept")
+	}
+}
+
+func TestFileEventStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events")
+	testEventStore(t, &FileEventStore{Path: file})
+
+	restarted := &FileEventStore{Path: file}
+	if ok, _ := restarted.Claim("a", time.Minute); ok {
+		t.Error("claims should survive a restart")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	pushed := &CallbackEvent{EventType: EVENT_USER_ADD_ORG, Data: []byte(`{"EventType":"user_add_org","TimeStamp":1,"UserId":["u1"],"CorpId":"c"}`)}
+	replayed, _ := failedCallbackEvent(map[string]json.RawMessage{
+		"call_back_tag": []byte(`"user_add_org"`),
+		"event_time":    []byte(`1`),
+		"userid":        []byte(`["u1"]`),
+		"corpid":        []byte(`"c"`),
+	})
+	if eventKey(pushed) != eventKey(replayed) {
+		t.Errorf("pushed and replayed events should have the same key: %s %s", eventKey(pushed), eventKey(replayed))
+	}
+	spaced := &CallbackEvent{EventType: EVENT_USER_ADD_ORG, Data: []byte(`{"EventType": "user_add_org", "TimeStamp": 1, "UserId": ["u1", "u2"], "CorpId": "c<&>"}`)}
+	replayedSpaced, _ := failedCallbackEvent(map[string]json.RawMessage{
+		"call_back_tag": []byte(`"user_add_org"`),
+		"event_time":    []byte(`1`),
+		"userid":        []byte(`["u1","u2"]`),
+		"corpid":        []byte(`"c<&>"`),
+	})
+	if eventKey(spaced) != eventKey(replayedSpaced) {
+		t.Errorf("keys should not depend on spacing or escaping: %s %s", eventKey(spaced), eventKey(replayedSpaced))
+	}
+	other := &CallbackEvent{EventType: EVENT_USER_ADD_ORG, Data: []byte(`{"EventType":"user_add_org","TimeStamp":2,"UserId":["u1"],"CorpId":"c"}`)}
+	if eventKey(pushed) == eventKey(other) {
+		t.Error("events at different times should have different keys")
+	}
+}
+
+func TestEventKeyApprovalTasks(t *testing.T) {
+	task := func(staffID string, result string) *CallbackEvent {
+		return &CallbackEvent{EventType: EVENT_BPMS_TASK_CHANGE, Data: []byte(`{"EventType":"bpms_task_change","corpId":"c","processInstanceId":"p1","type":"finish","result":"` + result + `","staffId":"` + staffID + `","createTime":1000,"finishTime":2000}`)}
+	}
+	if eventKey(task("alice", "agree")) == eventKey(task("bob", "agree")) {
+		t.Error("parallel approvers of an instance should have different keys")
+	}
+	if eventKey(task("alice", "agree")) == eventKey(task("alice", "refuse")) {
+		t.Error("tasks with different results should have different keys")
+	}
+}
+
+func TestCallbackHandlerDeduplicates(t *testing.T) {
+	crypto := NewCrypto("token", testAESKey, "corp")
+	calls := 0
+	fail := true
+	h := NewCallbackHandler(crypto, EventHandlerFunc(func(ctx context.Context, event *CallbackEvent) error {
+		calls++
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}))
+	h.Store = NewMemoryEventStore(100)
+	event := &CallbackEvent{EventType: EVENT_USER_ADD_ORG, Data: []byte(`{"EventType":"user_add_org","TimeStamp":1,"UserId":["u1"]}`)}
+
+	if err := h.dispatch(context.Background(), event); err == nil {
+		t.Fatal("expected the handler error")
+	}
+	fail = false
+	for i := 0; i < 3; i++ {
+		if err := h.dispatch(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected a retry after the failure and no more calls, got %d calls", calls)
+	}
+}
@@ -0,0 +1,174 @@
+package godingtalk
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+//ApprovalTaskEvent is the bpms_task_change event, for a task of an approval instance
+type ApprovalTaskEvent struct {
+	EventType         string `json:"EventType"`
+	ProcessInstanceID string `json:"processInstanceId"`
+	ProcessCode       string `json:"processCode"`
+	CorpID            string `json:"corpId"`
+	Title             string `json:"title"`
+	Type              string `json:"type"`   // start, finish, cancel
+	Result            string `json:"result"` // agree, refuse, redirect, 仅 finish 时有值
+	Remark            string `json:"remark"`
+	StaffID           string `json:"staffId"` // 任务处理人
+	URL               string `json:"url"`
+	CreateTime        int64  `json:"createTime"` // 毫秒
+	FinishTime        int64  `json:"finishTime"` // 毫秒
+}
+
+//ApprovalInstanceEvent is the bpms_instance_change event, for an approval instance
+type ApprovalInstanceEvent struct {
+	EventType         string `json:"EventType"`
+	ProcessInstanceID string `json:"processInstanceId"`
+	ProcessCode       string `json:"processCode"`
+	CorpID            string `json:"corpId"`
+	Title             string `json:"title"`
+	Type              string `json:"type"`    // start, finish, terminate
+	Result            string `json:"result"`  // agree, refuse, 仅 finish 时有值
+	StaffID           string `json:"staffId"` // 审批实例发起人
+	URL               string `json:"url"`
+	CreateTime        int64  `json:"createTime"` // 毫秒
+	FinishTime        int64  `json:"finishTime"` // 毫秒
+}
+
+//AttendanceEvent is the attendance_check_record event, for one or more check records
+type AttendanceEvent struct {
+	EventType string             `json:"EventType"`
+	CorpID    string             `json:"CorpId"`
+	Records   []AttendanceRecord `json:"DataList"`
+}
+
+//AttendanceRecord is 员工的一次打卡
+type AttendanceRecord struct {
+	UserID         string `json:"userId"`
+	CorpID         string `json:"corpId"`
+	BizID          string `json:"bizId"`
+	CheckTime      int64  `json:"checkTime"` // 毫秒
+	Address        string `json:"address"`
+	LocationMethod string `json:"locationMethod"`
+	DeviceID       string `json:"deviceId"`
+	DeviceName     string `json:"deviceName"`
+}
+
+//CheckInEvent is the check_in event, sent when a user checks in (签到)
+type CheckInEvent struct {
+	EventType string `json:"EventType"`
+	TimeStamp int64  `json:"TimeStamp"`
+	CorpID    string `json:"CorpId"`
+	StaffID   string `json:"StaffId"`
+}
+
+//ChatEvent is a chat_* event, sent when a chat created by the app changes
+type ChatEvent struct {
+	EventType       string   `json:"EventType"`
+	TimeStamp       int64    `json:"TimeStamp"`
+	CorpID          string   `json:"CorpId"`
+	ChatID          string   `json:"ChatId"`
+	Operator        string   `json:"Operator"`
+	OperatorUnionID string   `json:"OperatorUnionId"`
+	UserIDs         []string `json:"UserId"` // 被添加或删除的成员
+	Title           string   `json:"Title"`
+	Owner           string   `json:"Owner"`
+}
+
+//EventRouter is an EventHandler dispatching each event, fully decoded, to the
+//function registered for its type. Events without a function are logged and dropped
+type EventRouter struct {
+	Logger Logger
+
+	mu       sync.RWMutex
+	handlers map[string]EventHandlerFunc
+}
+
+//NewEventRouter creates an EventRouter without handlers
+func NewEventRouter() *EventRouter {
+	return &EventRouter{
+		handlers: map[string]EventHandlerFunc{},
+	}
+}
+
+//On registers fn for the events of the given type, which it decodes itself with CallbackEvent.Decode
+func (r *EventRouter) On(eventType string, fn EventHandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = map[string]EventHandlerFunc{}
+	}
+	r.handlers[eventType] = fn
+}
+
+//HandleEvent calls the function registered for the type of event
+func (r *EventRouter) HandleEvent(ctx context.Context, event *CallbackEvent) error {
+	r.mu.RLock()
+	fn := r.handlers[event.EventType]
+	r.mu.RUnlock()
+	if fn == nil {
+		if r.Logger != nil {
+			r.Logger.Log(ctx, slog.LevelInfo, "dingtalk event has no handler", "event_type", event.EventType)
+		}
+		return nil
+	}
+	return fn(ctx, event)
+}
+
+//onEvent registers fn for the events of the given type, decoded into a T
+func onEvent[T any](r *EventRouter, eventType string, fn func(ctx context.Context, event *T) error) {
+	r.On(eventType, func(ctx context.Context, event *CallbackEvent) error {
+		var v T
+		if err := event.Decode(&v); err != nil {
+			return err
+		}
+		return fn(ctx, &v)
+	})
+}
+
+//OnContactEvent registers fn for a contact event such as EVENT_ORG_DEPT_CREATE
+func (r *EventRouter) OnContactEvent(eventType string, fn func(ctx context.Context, event *ContactEvent) error) {
+	onEvent(r, eventType, fn)
+}
+
+//OnUserAddOrg registers fn for the user_add_org event
+func (r *EventRouter) OnUserAddOrg(fn func(ctx context.Context, event *ContactEvent) error) {
+	r.OnContactEvent(EVENT_USER_ADD_ORG, fn)
+}
+
+//OnUserModifyOrg registers fn for the user_modify_org event
+func (r *EventRouter) OnUserModifyOrg(fn func(ctx context.Context, event *ContactEvent) error) {
+	r.OnContactEvent(EVENT_USER_MODIFY_ORG, fn)
+}
+
+//OnUserLeaveOrg registers fn for the user_leave_org event
+func (r *EventRouter) OnUserLeaveOrg(fn func(ctx context.Context, event *ContactEvent) error) {
+	r.OnContactEvent(EVENT_USER_LEAVE_ORG, fn)
+}
+
+//OnApprovalTaskChange registers fn for the bpms_task_change event
+func (r *EventRouter) OnApprovalTaskChange(fn func(ctx context.Context, event *ApprovalTaskEvent) error) {
+	onEvent(r, EVENT_BPMS_TASK_CHANGE, fn)
+}
+
+//OnApprovalInstanceChange registers fn for the bpms_instance_change event
+func (r *EventRouter) OnApprovalInstanceChange(fn func(ctx context.Context, event *ApprovalInstanceEvent) error) {
+	onEvent(r, EVENT_BPMS_INSTANCE_CHANGE, fn)
+}
+
+//OnAttendanceCheckRecord registers fn for the attendance_check_record event
+func (r *EventRouter) OnAttendanceCheckRecord(fn func(ctx context.Context, event *AttendanceEvent) error) {
+	onEvent(r, EVENT_ATTENDANCE_CHECK_RECORD, fn)
+}
+
+//OnCheckIn registers fn for the check_in event
+func (r *EventRouter) OnCheckIn(fn func(ctx context.Context, event *CheckInEvent) error) {
+	onEvent(r, EVENT_CHECK_IN, fn)
+}
+
+//OnChatEvent registers fn for a chat event such as EVENT_CHAT_ADD_MEMBER
+func (r *EventRouter) OnChatEvent(eventType string, fn func(ctx context.Context, event *ChatEvent) error) {
+	onEvent(r, eventType, fn)
+}
@@ -0,0 +1,64 @@
+package godingtalk
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventRouter(t *testing.T) {
+	var approval *ApprovalInstanceEvent
+	var chat *ChatEvent
+	var unknown []string
+	router := NewEventRouter()
+	router.Logger = LoggerFunc(func(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+		unknown = append(unknown, args[1].(string))
+	})
+	router.OnApprovalInstanceChange(func(ctx context.Context, event *ApprovalInstanceEvent) error {
+		approval = event
+		return nil
+	})
+	router.OnChatEvent(EVENT_CHAT_ADD_MEMBER, func(ctx context.Context, event *ChatEvent) error {
+		chat = event
+		return nil
+	})
+
+	crypto := NewCrypto("token", testAESKey, "corp")
+	h := NewCallbackHandler(crypto, router)
+	events := []string{
+		`{"EventType":"bpms_instance_change","processInstanceId":"p1","type":"finish","result":"agree","staffId":"u1","createTime":1500000000000}`,
+		`{"EventType":"chat_add_member","ChatId":"chat1","Operator":"u1","UserId":["u2","u3"]}`,
+		`{"EventType":"org_remove"}`,
+	}
+	for i, event := range events {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, encryptedCallback(t, crypto, event, time.Now(), "nonce"+string(rune('a'+i))))
+		if w.Code != 200 {
+			t.Fatalf("%s: unexpected status %d", event, w.Code)
+		}
+	}
+
+	if approval == nil || approval.ProcessInstanceID != "p1" || approval.Result != "agree" || approval.CreateTime != 1500000000000 {
+		t.Errorf("unexpected approval event %+v", approval)
+	}
+	if chat == nil || chat.ChatID != "chat1" || len(chat.UserIDs) != 2 {
+		t.Errorf("unexpected chat event %+v", chat)
+	}
+	if len(unknown) != 1 || unknown[0] != EVENT_ORG_REMOVE {
+		t.Errorf("expected the unhandled event to be logged, got %v", unknown)
+	}
+}
+
+func TestEventRouterDecodeError(t *testing.T) {
+	router := NewEventRouter()
+	router.OnUserAddOrg(func(ctx context.Context, event *ContactEvent) error {
+		t.Error("handler must not be called for a malformed event")
+		return nil
+	})
+	err := router.HandleEvent(context.Background(), &CallbackEvent{EventType: EVENT_USER_ADD_ORG, Data: []byte(`{"UserId":"not a list"}`)})
+	if err == nil {
+		t.Error("expected a decode error")
+	}
+}
@@ -1,8 +1,10 @@
 package godingtalk
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"time"
@@ -22,10 +24,20 @@ type DingTalkClient struct {
 	CorpSecret  string
 	AgentID     string
 	AccessToken string
+	BaseURL     string
+	TaobaoURL   string
+	UserAgent   string
 	HTTPClient  *http.Client
 	Cache       Cache
+	TicketCache Cache // JS API ticket 的缓存, 为空时使用 Cache
+	Logger      Logger
+	RetryPolicy *RetryPolicy
+	RateLimiter *RateLimiter
 	*sync.RWMutex
 
+	accessTokenExpires time.Time
+	flight             flightGroup
+
 	//社交相关的属性
 	SnsAppID string
 	SnsAppSecret string
@@ -40,22 +52,28 @@ type Unmarshallable interface {
 
 //OAPIResponse is
 type OAPIResponse struct {
-	ErrCode int    `json:"errcode"`
-	ErrMsg  string `json:"errmsg"`
+	ErrCode   int    `json:"errcode"`
+	ErrMsg    string `json:"errmsg"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type TaobaoOAPIResponse struct {
 	ErrorResponse struct {
-		SubMsg  string `json:"sub_msg"`
-		Code    int
-		SubCode string `json:"sub_code"`
-		Msg     string
+		SubMsg    string `json:"sub_msg"`
+		Code      int
+		SubCode   string `json:"sub_code"`
+		Msg       string
+		RequestID string `json:"request_id"`
 	} `json:"error_response"`
 }
 
 func (data *OAPIResponse) checkError() (err error) {
 	if data.ErrCode != 0 {
-		err = fmt.Errorf("%d: %s", data.ErrCode, data.ErrMsg)
+		err = &APIError{
+			ErrCode:   data.ErrCode,
+			ErrMsg:    data.ErrMsg,
+			RequestID: data.RequestID,
+		}
 	}
 	return err
 }
@@ -67,7 +85,13 @@ func (data *OAPIResponse) getWriter() io.Writer {
 func (data *TaobaoOAPIResponse) checkError() (err error) {
 	errData := data.ErrorResponse
 	if errData.Code != 0 {
-		err = fmt.Errorf("code: %d, msg: %s, sub_code: %s, sub_msg: %s", errData.Code, errData.Msg, errData.SubCode, errData.SubMsg)
+		err = &TaobaoAPIError{
+			Code:      errData.Code,
+			Msg:       errData.Msg,
+			SubCode:   errData.SubCode,
+			SubMsg:    errData.SubMsg,
+			RequestID: errData.RequestID,
+		}
 	}
 	return err
 }
@@ -112,70 +136,180 @@ func (e *JsAPITicketResponse) ExpiresIn() int {
 	return e.Expires
 }
 
-//NewDingTalkClient creates a DingTalkClient instance
+//NewDingTalkClient creates a DingTalkClient instance which caches its access token in a file
 func NewDingTalkClient(corpID string, corpSecret string) *DingTalkClient {
-	c := new(DingTalkClient)
-	c.CorpID = corpID
-	c.CorpSecret = corpSecret
-	c.HTTPClient = &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	c.Cache = NewFileCache(".auth_file")
-	c.RWMutex = &sync.RWMutex{}
-	return c
+	return NewClient(corpID, corpSecret,
+		WithCache(NewFileCache(".auth_file")),
+		WithTicketCache(NewFileCache(".jsapi_ticket")),
+	)
 }
 
 //RefreshAccessToken is to get a valid access token
 func (c *DingTalkClient) RefreshAccessToken() error {
-	c.RLock()
-	var data AccessTokenResponse
-	err := c.Cache.Get(&data)
-	if err == nil {
-		c.AccessToken = data.AccessToken
-		c.RUnlock()
-		return nil
+	return c.RefreshAccessTokenContext(context.Background())
+}
+
+//RefreshAccessTokenContext is RefreshAccessToken with a context
+func (c *DingTalkClient) RefreshAccessTokenContext(ctx context.Context) error {
+	return c.refreshAccessToken(ctx, "")
+}
+
+//refreshAccessToken loads the access token from the cache or from DingTalk. A
+//non-empty stale token has been rejected by DingTalk, so a cached copy of it is
+//ignored. Concurrent refreshes are merged into a single request, which goes on
+//when the caller that started it gives up
+func (c *DingTalkClient) refreshAccessToken(ctx context.Context, stale string) error {
+	return c.flight.do(ctx, "access_token:"+stale, c.refreshTimeout(), func(ctx context.Context) error {
+		var data AccessTokenResponse
+		key := c.accessTokenCacheKey()
+		err := c.Cache.Get(key, &data)
+		if err == nil && (stale == "" || data.AccessToken != stale) {
+			c.setAccessToken(data.AccessToken, data.Created, data.Expires)
+			return nil
+		}
+
+		if stale != "" {
+			c.RLock()
+			current := c.AccessToken
+			c.RUnlock()
+			if current != stale {
+				return nil
+			}
+		}
+
+		params := url.Values{}
+		params.Add("corpid", c.CorpID)
+		params.Add("corpsecret", c.CorpSecret)
+		err = c.httpRPC(ctx, "gettoken", params, nil, &data)
+		if err == nil {
+			data.Expires = data.Expires | 7200
+			data.Created = time.Now().Unix()
+			c.setAccessToken(data.AccessToken, data.Created, data.Expires)
+			err = c.Cache.Set(key, &data)
+		}
+		return err
+	})
+}
+
+//refreshTimeout bounds a shared token refresh, retries included
+func (c *DingTalkClient) refreshTimeout() time.Duration {
+	if c.HTTPClient != nil && c.HTTPClient.Timeout > 0 {
+		return 3 * c.HTTPClient.Timeout
 	}
-	c.RUnlock()
+	return 30 * time.Second
+}
 
+func (c *DingTalkClient) setAccessToken(token string, created int64, expires int) {
 	c.Lock()
 	defer c.Unlock()
+	c.AccessToken = token
+	c.accessTokenExpires = time.Unix(created+int64(expires-60), 0)
+}
 
-	params := url.Values{}
-	params.Add("corpid", c.CorpID)
-	params.Add("corpsecret", c.CorpSecret)
-	err = c.httpRPC("gettoken", params, nil, &data)
-	if err == nil {
-		c.AccessToken = data.AccessToken
-		data.Expires = data.Expires | 7200
-		data.Created = time.Now().Unix()
-		err = c.Cache.Set(&data)
+//accessToken returns the current access token, fetching a new one when there is
+//none yet or it has expired
+func (c *DingTalkClient) accessToken(ctx context.Context) (string, error) {
+	c.RLock()
+	token, expires := c.AccessToken, c.accessTokenExpires
+	c.RUnlock()
+	if token != "" && (expires.IsZero() || time.Now().Before(expires)) {
+		return token, nil
 	}
-	return err
+	if err := c.RefreshAccessTokenContext(ctx); err != nil {
+		return "", err
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.AccessToken, nil
+}
+
+//StartTokenRefresher keeps the access token fresh in the background, renewing it
+//the given duration before it expires, so that requests never wait for gettoken.
+//It stops when ctx is done
+func (c *DingTalkClient) StartTokenRefresher(ctx context.Context, before time.Duration) {
+	go func() {
+		var last time.Time
+		for {
+			c.RLock()
+			token, expires := c.AccessToken, c.accessTokenExpires
+			c.RUnlock()
+
+			wait := time.Until(expires.Add(-before))
+			if token == "" || expires.IsZero() {
+				wait = 0
+			}
+			// never renew more than once a minute, even if before exceeds the token's lifetime
+			if min := time.Until(last.Add(time.Minute)); wait < min {
+				wait = min
+			}
+			if sleep(ctx, wait) != nil {
+				return
+			}
+			last = time.Now()
+
+			if err := c.refreshAccessToken(ctx, token); err != nil {
+				c.log(ctx, slog.LevelWarn, "background access token refresh failed", "error", err.Error())
+				if sleep(ctx, time.Minute) != nil {
+					return
+				}
+			}
+		}
+	}()
 }
 
 //GetJsAPITicket is to get a valid ticket for JS API
 func (c *DingTalkClient) GetJsAPITicket() (ticket string, err error) {
+	return c.GetJsAPITicketContext(context.Background())
+}
+
+//GetJsAPITicketContext is GetJsAPITicket with a context
+func (c *DingTalkClient) GetJsAPITicketContext(ctx context.Context) (ticket string, err error) {
 	var data JsAPITicketResponse
-	cache := NewFileCache(".jsapi_ticket")
-	err = cache.Get(&data)
+	cache := c.ticketCache()
+	err = cache.Get(c.jsapiTicketCacheKey(), &data)
 	if err == nil {
 		return data.Ticket, err
 	}
-	err = c.httpRPC("get_jsapi_ticket", nil, nil, &data)
+	err = c.httpRPC(ctx, "get_jsapi_ticket", nil, nil, &data)
 	if err == nil {
 		ticket = data.Ticket
-		cache.Set(&data)
+		data.Created = time.Now().Unix()
+		cache.Set(c.jsapiTicketCacheKey(), &data)
 	}
 	return ticket, err
 }
 
+//accessTokenCacheKey is where the app's access token is cached. Apps of the same
+//corp have their own tokens, so the key includes a hash of the secret
+func (c *DingTalkClient) accessTokenCacheKey() string {
+	return "access_token:" + c.CorpID + ":" + sha1Sign(c.CorpSecret)[:16]
+}
+
+//jsapiTicketCacheKey is where the corp's JS API ticket is cached. The ticket is
+//issued per corp, so all agents of the corp share it
+func (c *DingTalkClient) jsapiTicketCacheKey() string {
+	return "jsapi_ticket:" + c.CorpID
+}
+
+func (c *DingTalkClient) ticketCache() Cache {
+	if c.TicketCache != nil {
+		return c.TicketCache
+	}
+	return c.Cache
+}
+
 //GetConfig is to return config in json
 func (c *DingTalkClient) GetConfig(nonceStr string, timestamp string, url string) (map[string]string, error) {
-	ticket, err := c.GetJsAPITicket()
+	return c.GetConfigContext(context.Background(), nonceStr, timestamp, url)
+}
+
+//GetConfigContext is GetConfig with a context
+func (c *DingTalkClient) GetConfigContext(ctx context.Context, nonceStr string, timestamp string, url string) (map[string]string, error) {
+	ticket, err := c.GetJsAPITicketContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("jsapi config: {\"noncestr\":\"%s\",\"timestamp\":\"%s\",\"url\":\"%s\",\"jsapi_ticket\":\"%s\"}\n", nonceStr, timestamp, url, ticket)
+	c.log(ctx, slog.LevelDebug, "jsapi config", "noncestr", nonceStr, "timestamp", timestamp, "url", url, "jsapi_ticket", redacted)
 	return map[string]string{
 		"nonceStr":  nonceStr,
 		"agentId":   c.AgentID,
@@ -0,0 +1,100 @@
+package godingtalk
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+//KVStore is a minimal key-value store with expiry, such as Redis or memcached,
+//which lets several instances of a service share tokens and tickets
+type KVStore interface {
+	// Get returns ErrCacheMiss when nothing is stored under key
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+//CASStore is a KVStore which can also replace a value atomically
+type CASStore interface {
+	KVStore
+	// CompareAndSwap stores value only if key currently holds old, or holds
+	// nothing when old is nil, and reports whether it did so
+	CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+//KVCache is a Cache on top of a KVStore. When the store is a CASStore, an entry
+//is never replaced by data which was created earlier, so a slow instance cannot
+//overwrite the token another instance has just fetched
+type KVCache struct {
+	Store  KVStore
+	Prefix string
+}
+
+//NewKVCache creates a Cache which keeps its entries in store, with every key prefixed by prefix
+func NewKVCache(store KVStore, prefix string) *KVCache {
+	return &KVCache{
+		Store:  store,
+		Prefix: prefix,
+	}
+}
+
+//casRetries bounds how often Set retries when other instances keep changing the entry
+const casRetries = 3
+
+func (c *KVCache) Set(key string, data Expirable) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(time.Unix(data.CreatedAt()+int64(data.ExpiresIn()), 0))
+	if ttl <= 0 {
+		return nil
+	}
+	key = c.Prefix + key
+
+	cas, ok := c.Store.(CASStore)
+	if !ok {
+		return c.Store.Set(key, value, ttl)
+	}
+	for i := 0; i < casRetries; i++ {
+		old, err := cas.Get(key)
+		if err == ErrCacheMiss {
+			old = nil
+		} else if err != nil {
+			return err
+		} else if bytes.Equal(old, value) || newerThan(old, data) {
+			return nil
+		}
+		swapped, err := cas.CompareAndSwap(key, old, value, ttl)
+		if err != nil || swapped {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *KVCache) Get(key string, data Expirable) error {
+	value, err := c.Store.Get(c.Prefix + key)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(value, data)
+	if err == nil {
+		err = checkExpired(data)
+	}
+	return err
+}
+
+//newerThan reports whether the stored value was created after data
+func newerThan(stored []byte, data Expirable) bool {
+	t := reflect.TypeOf(data)
+	if t.Kind() != reflect.Ptr {
+		return false
+	}
+	existing, ok := reflect.New(t.Elem()).Interface().(Expirable)
+	if !ok || json.Unmarshal(stored, existing) != nil {
+		return false
+	}
+	return existing.CreatedAt() > data.CreatedAt()
+}
@@ -0,0 +1,57 @@
+package godingtalk
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+)
+
+//Logger receives diagnostic logs from DingTalkClient. A *slog.Logger can be used directly
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...interface{})
+}
+
+//LoggerFunc adapts an ordinary function to the Logger interface
+type LoggerFunc func(ctx context.Context, level slog.Level, msg string, args ...interface{})
+
+//Log calls f(ctx, level, msg, args...)
+func (f LoggerFunc) Log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	f(ctx, level, msg, args...)
+}
+
+//redacted replaces the value of sensitive parameters in logs
+const redacted = "REDACTED"
+
+//sensitiveParams are the query and form parameters which must never be logged
+var sensitiveParams = []string{
+	"access_token",
+	"session",
+	"corpsecret",
+	"appsecret",
+	"ticket",
+	"jsapi_ticket",
+	"sns_token",
+}
+
+//redactParams encodes params for logging with all sensitive values hidden
+func redactParams(params url.Values) string {
+	if len(params) == 0 {
+		return ""
+	}
+	safe := url.Values{}
+	for k, v := range params {
+		safe[k] = v
+	}
+	for _, k := range sensitiveParams {
+		if _, ok := safe[k]; ok {
+			safe.Set(k, redacted)
+		}
+	}
+	return safe.Encode()
+}
+
+func (c *DingTalkClient) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Log(ctx, level, msg, args...)
+	}
+}
@@ -0,0 +1,45 @@
+package godingtalk
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(200, `{"errcode":60121,"errmsg":"找不到该用户"}`), nil
+	})
+	client.AccessToken = "supersecrettoken"
+	client.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	client.UserDetail("nobody")
+
+	out := buf.String()
+	if strings.Contains(out, "supersecrettoken") {
+		t.Errorf("access token leaked into logs: %s", out)
+	}
+	for _, s := range []string{"method=GET", "path=user/get", "errcode=60121", "duration="} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in logs: %s", s, out)
+		}
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	params := map[string][]string{
+		"corpid":     {"ding123"},
+		"corpsecret": {"s3cr3t"},
+		"session":    {"t0k3n"},
+	}
+	s := redactParams(params)
+	if s != "corpid=ding123&corpsecret=REDACTED&session=REDACTED" {
+		t.Errorf("unexpected redacted params %q", s)
+	}
+	if params["corpsecret"][0] != "s3cr3t" {
+		t.Error("redactParams must not modify its input")
+	}
+}
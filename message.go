@@ -0,0 +1,133 @@
+package godingtalk
+
+//Message is the body of any DingTalk message. It can be sent as a work notice,
+//to a group chat or through a robot
+type Message interface {
+	MsgType() string
+}
+
+//robotMessage is implemented by messages which robots expect in a different shape
+type robotMessage interface {
+	robotMsgType() string
+	robotBody() interface{}
+}
+
+//TextMessage is 文本消息
+type TextMessage struct {
+	Content string `json:"content"`
+}
+
+//ImageMessage is 图片消息
+type ImageMessage struct {
+	MediaID string `json:"media_id"`
+}
+
+//VoiceMessage is 语音消息
+type VoiceMessage struct {
+	MediaID  string `json:"media_id"`
+	Duration string `json:"duration"` // 正整数，小于60，表示音频时长
+}
+
+//FileMessage is 文件消息
+type FileMessage struct {
+	MediaID string `json:"media_id"`
+}
+
+//LinkMessage is 链接消息
+type LinkMessage struct {
+	MessageURL string `json:"messageUrl"`
+	PicURL     string `json:"picUrl"` // 图片的media_id或url
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+}
+
+//MarkdownMessage is markdown消息
+type MarkdownMessage struct {
+	Title string `json:"title"` // 首屏会话透出的展示内容
+	Text  string `json:"text"`
+}
+
+//ActionCardMessage is 卡片消息. Set SingleTitle and SingleURL for a card with a
+//single button, or Buttons for a card with several
+type ActionCardMessage struct {
+	Title          string             `json:"title"`
+	Markdown       string             `json:"markdown"`
+	SingleTitle    string             `json:"single_title,omitempty"`
+	SingleURL      string             `json:"single_url,omitempty"`
+	BtnOrientation string             `json:"btn_orientation,omitempty"` // "0" 竖直排列, "1" 横向排列
+	Buttons        []ActionCardButton `json:"btn_json_list,omitempty"`
+}
+
+//ActionCardButton is a button of a multi-button ActionCardMessage
+type ActionCardButton struct {
+	Title     string `json:"title"`
+	ActionURL string `json:"action_url"`
+}
+
+//FeedCardMessage is FeedCard消息, which only robots can send
+type FeedCardMessage struct {
+	Links []FeedCardLink `json:"links"`
+}
+
+//FeedCardLink is an entry of a FeedCardMessage
+type FeedCardLink struct {
+	Title      string `json:"title"`
+	MessageURL string `json:"messageURL"`
+	PicURL     string `json:"picURL"`
+}
+
+func (TextMessage) MsgType() string       { return "text" }
+func (ImageMessage) MsgType() string      { return "image" }
+func (VoiceMessage) MsgType() string      { return "voice" }
+func (FileMessage) MsgType() string       { return "file" }
+func (LinkMessage) MsgType() string       { return "link" }
+func (MarkdownMessage) MsgType() string   { return "markdown" }
+func (OAMessage) MsgType() string         { return "oa" }
+func (ActionCardMessage) MsgType() string { return "action_card" }
+func (FeedCardMessage) MsgType() string   { return "feedCard" }
+
+func (ActionCardMessage) robotMsgType() string { return "actionCard" }
+
+func (m ActionCardMessage) robotBody() interface{} {
+	type button struct {
+		Title     string `json:"title"`
+		ActionURL string `json:"actionURL"`
+	}
+	body := struct {
+		Title          string   `json:"title"`
+		Text           string   `json:"text"`
+		SingleTitle    string   `json:"singleTitle,omitempty"`
+		SingleURL      string   `json:"singleURL,omitempty"`
+		BtnOrientation string   `json:"btnOrientation,omitempty"`
+		Buttons        []button `json:"btns,omitempty"`
+	}{
+		Title:          m.Title,
+		Text:           m.Markdown,
+		SingleTitle:    m.SingleTitle,
+		SingleURL:      m.SingleURL,
+		BtnOrientation: m.BtnOrientation,
+	}
+	for _, b := range m.Buttons {
+		body.Buttons = append(body.Buttons, button{Title: b.Title, ActionURL: b.ActionURL})
+	}
+	return body
+}
+
+//messagePayload is the request body of msg, which callers complete with the receivers
+func messagePayload(msg Message) map[string]interface{} {
+	return map[string]interface{}{
+		"msgtype":     msg.MsgType(),
+		msg.MsgType(): msg,
+	}
+}
+
+//robotMessagePayload is the request body of msg sent through a robot
+func robotMessagePayload(msg Message) map[string]interface{} {
+	if m, ok := msg.(robotMessage); ok {
+		return map[string]interface{}{
+			"msgtype":        m.robotMsgType(),
+			m.robotMsgType(): m.robotBody(),
+		}
+	}
+	return messagePayload(msg)
+}
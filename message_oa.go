@@ -0,0 +1,163 @@
+package godingtalk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//MarshalJSON leaves out the head and body of m when they are empty
+func (m OAMessage) MarshalJSON() ([]byte, error) {
+	type message OAMessage
+	v := struct {
+		message
+		Head *OAMessageHead `json:"head,omitempty"`
+		Body *OAMessageBody `json:"body,omitempty"`
+	}{message: message(m)}
+	if m.Head != (OAMessageHead{}) {
+		v.Head = &m.Head
+	}
+	if !m.Body.isEmpty() {
+		v.Body = &m.Body
+	}
+	return json.Marshal(v)
+}
+
+//MarshalJSON leaves out the rich part of b when it is empty
+func (b OAMessageBody) MarshalJSON() ([]byte, error) {
+	type body OAMessageBody
+	v := struct {
+		body
+		Rich *OAMessageRich `json:"rich,omitempty"`
+	}{body: body(b)}
+	if b.Rich != (OAMessageRich{}) {
+		v.Rich = &b.Rich
+	}
+	return json.Marshal(v)
+}
+
+func (b *OAMessageBody) isEmpty() bool {
+	return b.Title == "" && len(b.Form) == 0 && b.Rich == (OAMessageRich{}) &&
+		b.Content == "" && b.Image == "" && b.FileCount == 0 && b.Author == ""
+}
+
+//Validate checks that m has the fields DingTalk requires, valid colors, and fits
+//within the limits on head text, title and form rows
+func (m *OAMessage) Validate() error {
+	if m.URL == "" {
+		return errors.New("oa message: message url is required")
+	}
+	if m.Head.BgColor == "" {
+		return errors.New("oa message: head bgcolor is required")
+	}
+	if m.Body.isEmpty() {
+		return errors.New("oa message: body is empty")
+	}
+	if m.StatusBar != nil {
+		if err := m.StatusBar.validate(); err != nil {
+			return err
+		}
+	}
+	return m.checkLimits()
+}
+
+func (s *OAStatusBar) validate() error {
+	if s.Value == "" {
+		return errors.New("oa message: status bar value is required")
+	}
+	if s.BgColor != "" && !argbColor.MatchString(strings.TrimPrefix(s.BgColor, "0x")) {
+		return fmt.Errorf("oa message: status bar color %q is not an ARGB color like 0xFF78C06E", s.BgColor)
+	}
+	return nil
+}
+
+//OAMessageBuilder builds an OAMessage step by step:
+//
+//	msg, err := NewOAMessage().URL(url).Head("Github", "FF00AABB").Title("Push").
+//		Form("Branch: ", "master").Rich("3", "commits").Author("hugozhu").Build()
+type OAMessageBuilder struct {
+	msg OAMessage
+}
+
+//NewOAMessage starts building an OAMessage
+func NewOAMessage() *OAMessageBuilder {
+	return &OAMessageBuilder{}
+}
+
+//URL sets the url opened when the message is clicked, and on PCs unless PcURL is set
+func (b *OAMessageBuilder) URL(url string) *OAMessageBuilder {
+	b.msg.URL = url
+	return b
+}
+
+//PcURL sets the url opened when the message is clicked on a PC
+func (b *OAMessageBuilder) PcURL(url string) *OAMessageBuilder {
+	b.msg.PcURL = url
+	return b
+}
+
+//Head sets the head text and its ARGB background color
+func (b *OAMessageBuilder) Head(text string, bgColor string) *OAMessageBuilder {
+	b.msg.Head = OAMessageHead{BgColor: bgColor, Text: text}
+	return b
+}
+
+//Title sets the title of the body
+func (b *OAMessageBuilder) Title(title string) *OAMessageBuilder {
+	b.msg.Body.Title = title
+	return b
+}
+
+//Form appends a row to the form of the body
+func (b *OAMessageBuilder) Form(key string, value string) *OAMessageBuilder {
+	b.msg.AppendFormItem(key, value)
+	return b
+}
+
+//Rich sets the highlighted number of the body, and its unit
+func (b *OAMessageBuilder) Rich(num string, unit string) *OAMessageBuilder {
+	b.msg.Body.Rich = OAMessageRich{Num: num, Unit: unit}
+	return b
+}
+
+//Content sets the text of the body
+func (b *OAMessageBuilder) Content(content string) *OAMessageBuilder {
+	b.msg.Body.Content = content
+	return b
+}
+
+//Image sets the media_id of the image of the body
+func (b *OAMessageBuilder) Image(mediaID string) *OAMessageBuilder {
+	b.msg.Body.Image = mediaID
+	return b
+}
+
+//FileCount sets the number of attachments shown in the body
+func (b *OAMessageBuilder) FileCount(n int) *OAMessageBuilder {
+	b.msg.Body.FileCount = n
+	return b
+}
+
+//Author sets the author of the body
+func (b *OAMessageBuilder) Author(author string) *OAMessageBuilder {
+	b.msg.Body.Author = author
+	return b
+}
+
+//StatusBar sets the status bar of a work notice and its ARGB background color
+func (b *OAMessageBuilder) StatusBar(value string, bgColor string) *OAMessageBuilder {
+	b.msg.StatusBar = &OAStatusBar{Value: value, BgColor: bgColor}
+	return b
+}
+
+//Build validates and returns the message
+func (b *OAMessageBuilder) Build() (OAMessage, error) {
+	msg := b.msg
+	msg.Body.Form = append([]OAMessageForm(nil), b.msg.Body.Form...)
+	if msg.StatusBar != nil {
+		bar := *msg.StatusBar
+		msg.StatusBar = &bar
+	}
+	return msg, msg.Validate()
+}
@@ -0,0 +1,73 @@
+package godingtalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOAMessageJSON(t *testing.T) {
+	msg, err := NewOAMessage().URL("http://u").Head("Github", "FF00AABB").Title("Push").
+		Form("Branch: ", "master").Rich("3", "commits").Author("hugozhu").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(msg)
+	expected := `{"message_url":"http://u","head":{"bgcolor":"FF00AABB","text":"Github"},` +
+		`"body":{"title":"Push","form":[{"key":"Branch: ","value":"master"}],"author":"hugozhu","rich":{"num":"3","unit":"commits"}}}`
+	if string(b) != expected {
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+
+	b, _ = json.Marshal(OAMessage{URL: "http://u", Body: OAMessageBody{Content: "x"}})
+	if string(b) != `{"message_url":"http://u","body":{"content":"x"}}` {
+		t.Errorf("empty head and rich should be left out, got %s", b)
+	}
+
+	var decoded OAMessage
+	if err = json.Unmarshal([]byte(expected), &decoded); err != nil || !reflect.DeepEqual(decoded, msg) {
+		t.Errorf("round trip failed: %+v %v", decoded, err)
+	}
+}
+
+func TestOAMessageValidate(t *testing.T) {
+	cases := map[string]*OAMessageBuilder{
+		"no url":       NewOAMessage().Head("h", "FFBBBBBB").Title("t"),
+		"no bgcolor":   NewOAMessage().URL("u").Title("t"),
+		"bad bgcolor":  NewOAMessage().URL("u").Head("h", "#BBBBBB").Title("t"),
+		"empty body":   NewOAMessage().URL("u").Head("h", "FFBBBBBB"),
+		"bad status":   NewOAMessage().URL("u").Head("h", "FFBBBBBB").Title("t").StatusBar("done", "green"),
+		"long head":    NewOAMessage().URL("u").Head("0123456789a", "FFBBBBBB").Title("t"),
+		"empty status": NewOAMessage().URL("u").Head("h", "FFBBBBBB").Title("t").StatusBar("", ""),
+	}
+	for name, b := range cases {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+	if _, err := NewOAMessage().URL("u").Head("h", "FFBBBBBB").Title("t").StatusBar("待审批", "0xFF78C06E").Build(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestUpdateWorkNoticeStatusBar(t *testing.T) {
+	var request map[string]interface{}
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/topapi/message/corpconversation/status_bar/update" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &request)
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	if err := client.UpdateWorkNoticeStatusBar("123", 456, OAStatusBar{Value: "已同意", BgColor: "0xFF78C06E"}); err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"agent_id":123,"task_id":456,"status_value":"已同意","status_bg":"0xFF78C06E"}`), &expected)
+	if !reflect.DeepEqual(request, expected) {
+		t.Errorf("got %v, expected %v", request, expected)
+	}
+}
@@ -0,0 +1,196 @@
+package godingtalk
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+//Limits of OA message fields, beyond which DingTalk truncates or hides the content
+const (
+	MaxOAHeadTextLength = 10 // 消息头部标题最多10个字符
+	MaxOATitleLength    = 50 // 消息体的标题建议50个字符以内
+	MaxOAFormRows       = 6  // 表单最多显示6行, 超过会隐藏
+)
+
+var argbColor = regexp.MustCompile(`^[0-9A-Fa-f]{8}$`)
+
+//checkLimits reports the first field of m which DingTalk would not display as is
+func (m *OAMessage) checkLimits() error {
+	if m.Head.BgColor != "" && !argbColor.MatchString(m.Head.BgColor) {
+		return fmt.Errorf("oa message: head bgcolor %q is not an ARGB color like FFBBBBBB", m.Head.BgColor)
+	}
+	if n := utf8.RuneCountInString(m.Head.Text); n > MaxOAHeadTextLength {
+		return fmt.Errorf("oa message: head text has %d characters, at most %d are allowed", n, MaxOAHeadTextLength)
+	}
+	if n := utf8.RuneCountInString(m.Body.Title); n > MaxOATitleLength {
+		return fmt.Errorf("oa message: title has %d characters, at most %d are allowed", n, MaxOATitleLength)
+	}
+	if n := len(m.Body.Form); n > MaxOAFormRows {
+		return fmt.Errorf("oa message: form has %d rows, at most %d are shown", n, MaxOAFormRows)
+	}
+	return nil
+}
+
+//OAMessageTemplate defines an OAMessage whose fields are text/template templates,
+//executed with the data passed to Execute. Missing map keys are errors. Rows is a template which adds any number
+//of form rows by calling row, e.g.
+//
+//	{{range .Commits}}{{row "Commit: " .Message}}{{end}}
+type OAMessageTemplate struct {
+	URL         string
+	PcURL       string
+	HeadBgColor string
+	HeadText    string
+	Title       string
+	Form        []OAMessageForm // 键和值都为空的行会被忽略
+	Rows        string
+	RichNum     string
+	RichUnit    string
+	Content     string
+	Image       string
+	Author      string
+
+	tmpl *template.Template
+}
+
+//MarkdownTemplate defines a MarkdownMessage whose title and text are text/template templates
+type MarkdownTemplate struct {
+	Title string
+	Text  string
+
+	tmpl *template.Template
+}
+
+//errRowOutsideRows is returned when row is called from a template other than Rows
+var errRowOutsideRows = errors.New("row can only be called from the Rows template")
+
+//Parse compiles the templates of t. Execute calls it when needed, but it must be
+//called first when t is shared by several goroutines
+func (t *OAMessageTemplate) Parse() error {
+	tmpl := template.New("oa").Option("missingkey=error").Funcs(template.FuncMap{
+		"row": func(key, value string) (string, error) { return "", errRowOutsideRows },
+	})
+	fields := map[string]string{
+		"url":       t.URL,
+		"pc_url":    t.PcURL,
+		"bgcolor":   t.HeadBgColor,
+		"head":      t.HeadText,
+		"title":     t.Title,
+		"rows":      t.Rows,
+		"rich_num":  t.RichNum,
+		"rich_unit": t.RichUnit,
+		"content":   t.Content,
+		"image":     t.Image,
+		"author":    t.Author,
+	}
+	for i, f := range t.Form {
+		fields[fmt.Sprintf("form_key_%d", i)] = f.Key
+		fields[fmt.Sprintf("form_value_%d", i)] = f.Value
+	}
+	for name, text := range fields {
+		if _, err := tmpl.New(name).Parse(text); err != nil {
+			return err
+		}
+	}
+	t.tmpl = tmpl
+	return nil
+}
+
+//Execute renders the templates with data into an OAMessage, and checks the result
+//against DingTalk's limits on head text, title, form rows and colors
+func (t *OAMessageTemplate) Execute(data interface{}) (*OAMessage, error) {
+	if t.tmpl == nil {
+		if err := t.Parse(); err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	var rows []OAMessageForm
+	inRows := false
+	tmpl.Funcs(template.FuncMap{
+		"row": func(key, value string) (string, error) {
+			if !inRows {
+				return "", errRowOutsideRows
+			}
+			rows = append(rows, OAMessageForm{Key: key, Value: value})
+			return "", nil
+		},
+	})
+
+	exec := func(name string) string {
+		if err != nil {
+			return ""
+		}
+		var sb strings.Builder
+		err = tmpl.ExecuteTemplate(&sb, name, data)
+		return sb.String()
+	}
+	msg := &OAMessage{}
+	msg.URL = exec("url")
+	msg.PcURL = exec("pc_url")
+	msg.Head.BgColor = exec("bgcolor")
+	msg.Head.Text = exec("head")
+	msg.Body.Title = exec("title")
+	for i := range t.Form {
+		key, value := exec(fmt.Sprintf("form_key_%d", i)), exec(fmt.Sprintf("form_value_%d", i))
+		if key != "" || value != "" {
+			msg.AppendFormItem(key, value)
+		}
+	}
+	inRows = true
+	exec("rows")
+	inRows = false
+	msg.Body.Form = append(msg.Body.Form, rows...)
+	msg.Body.Rich.Num = exec("rich_num")
+	msg.Body.Rich.Unit = exec("rich_unit")
+	msg.Body.Content = exec("content")
+	msg.Body.Image = exec("image")
+	msg.Body.Author = exec("author")
+	if err != nil {
+		return nil, err
+	}
+	if err = msg.checkLimits(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+//Parse compiles the templates of t, as OAMessageTemplate.Parse does
+func (t *MarkdownTemplate) Parse() error {
+	tmpl := template.New("markdown").Option("missingkey=error")
+	if _, err := tmpl.New("title").Parse(t.Title); err != nil {
+		return err
+	}
+	if _, err := tmpl.New("text").Parse(t.Text); err != nil {
+		return err
+	}
+	t.tmpl = tmpl
+	return nil
+}
+
+//Execute renders the templates with data into a MarkdownMessage
+func (t *MarkdownTemplate) Execute(data interface{}) (*MarkdownMessage, error) {
+	if t.tmpl == nil {
+		if err := t.Parse(); err != nil {
+			return nil, err
+		}
+	}
+	var title, text strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&title, "title", data); err != nil {
+		return nil, err
+	}
+	if err := t.tmpl.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, err
+	}
+	if title.Len() == 0 {
+		return nil, errors.New("markdown message: title is empty")
+	}
+	return &MarkdownMessage{Title: title.String(), Text: text.String()}, nil
+}
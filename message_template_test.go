@@ -0,0 +1,82 @@
+package godingtalk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOAMessageTemplate(t *testing.T) {
+	tmpl := &OAMessageTemplate{
+		URL:         "{{.URL}}",
+		HeadBgColor: "FF00AABB",
+		HeadText:    "Github",
+		Title:       "[{{.Repo}}] Push",
+		Form:        []OAMessageForm{{Key: "Branch: ", Value: "{{.Branch}}"}, {Key: "{{if .Tag}}Tag: {{end}}", Value: "{{.Tag}}"}},
+		Rows:        `{{range .Commits}}{{row "Commit: " .}}{{end}}`,
+		RichNum:     "{{len .Commits}}",
+		RichUnit:    "commits",
+		Author:      "{{.Sender}}",
+	}
+	if err := tmpl.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"URL":     "https://github.com/hugozhu/godingtalk/compare/a...b",
+		"Repo":    "godingtalk",
+		"Branch":  "master",
+		"Tag":     "",
+		"Commits": []string{"fix cache", "add robot"},
+		"Sender":  "hugozhu",
+	}
+	msg, err := tmpl.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.URL != data["URL"] || msg.Body.Title != "[godingtalk] Push" || msg.Body.Author != "hugozhu" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	expected := []OAMessageForm{{"Branch: ", "master"}, {"Commit: ", "fix cache"}, {"Commit: ", "add robot"}}
+	if !reflect.DeepEqual(msg.Body.Form, expected) {
+		t.Errorf("got form %v, expected %v", msg.Body.Form, expected)
+	}
+	if msg.Body.Rich.Num != "2" || msg.Body.Rich.Unit != "commits" {
+		t.Errorf("unexpected rich %+v", msg.Body.Rich)
+	}
+
+	data["Commits"] = []string{"1", "2", "3", "4", "5", "6"}
+	if _, err = tmpl.Execute(data); err == nil || !strings.Contains(err.Error(), "rows") {
+		t.Errorf("expected too many form rows, got %v", err)
+	}
+}
+
+func TestOAMessageTemplateErrors(t *testing.T) {
+	cases := map[string]*OAMessageTemplate{
+		"bad color":   {HeadBgColor: "{{.}}"},
+		"long title":  {Title: "{{.}}{{.}}{{.}}{{.}}{{.}}{{.}}"},
+		"row in head": {HeadText: `{{row "k" "v"}}`},
+		"parse error": {Title: "{{.Title"},
+	}
+	for name, tmpl := range cases {
+		if _, err := tmpl.Execute("#00AABBCCDD"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMarkdownTemplate(t *testing.T) {
+	tmpl := &MarkdownTemplate{Title: "{{.Service}} alert", Text: "### {{.Service}}\n{{range .Hosts}}- {{.}}\n{{end}}"}
+	msg, err := tmpl.Execute(map[string]interface{}{"Service": "api", "Hosts": []string{"h1", "h2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Title != "api alert" || msg.Text != "### api\n- h1\n- h2\n" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if _, err = (&MarkdownTemplate{Title: "{{.Empty}}"}).Execute(map[string]string{"Empty": ""}); err == nil {
+		t.Error("expected an error for an empty title")
+	}
+	if _, err = (&MarkdownTemplate{Title: "{{.Missing}}"}).Execute(map[string]string{}); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
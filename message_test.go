@@ -0,0 +1,81 @@
+package godingtalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+//captureRequests returns a client which records the JSON body of each request
+func captureRequests(bodies *[]map[string]interface{}) *DingTalkClient {
+	return newStubClient(func(r *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		*bodies = append(*bodies, body)
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+}
+
+func TestMessagePayloads(t *testing.T) {
+	cases := []struct {
+		msg      Message
+		expected string
+	}{
+		{TextMessage{Content: "hi"}, `{"msgtype":"text","text":{"content":"hi"}}`},
+		{ImageMessage{MediaID: "@m"}, `{"msgtype":"image","image":{"media_id":"@m"}}`},
+		{VoiceMessage{MediaID: "@m", Duration: "10"}, `{"msgtype":"voice","voice":{"media_id":"@m","duration":"10"}}`},
+		{FileMessage{MediaID: "@m"}, `{"msgtype":"file","file":{"media_id":"@m"}}`},
+		{LinkMessage{MessageURL: "u", PicURL: "p", Title: "t", Text: "x"}, `{"msgtype":"link","link":{"messageUrl":"u","picUrl":"p","title":"t","text":"x"}}`},
+		{&MarkdownMessage{Title: "t", Text: "# x"}, `{"msgtype":"markdown","markdown":{"title":"t","text":"# x"}}`},
+		{ActionCardMessage{Title: "t", Markdown: "m", SingleTitle: "go", SingleURL: "u"},
+			`{"msgtype":"action_card","action_card":{"title":"t","markdown":"m","single_title":"go","single_url":"u"}}`},
+	}
+	for _, tc := range cases {
+		var bodies []map[string]interface{}
+		client := captureRequests(&bodies)
+		if _, err := client.SendChatMessage("chat", "sender", tc.msg); err != nil {
+			t.Fatal(err)
+		}
+		var expected map[string]interface{}
+		json.Unmarshal([]byte(tc.expected), &expected)
+		expected["chatid"] = "chat"
+		expected["sender"] = "sender"
+		if !reflect.DeepEqual(bodies[0], expected) {
+			t.Errorf("%s: got %v, expected %v", tc.msg.MsgType(), bodies[0], expected)
+		}
+	}
+}
+
+func TestRobotActionCardPayload(t *testing.T) {
+	var bodies []map[string]interface{}
+	client := captureRequests(&bodies)
+	msg := ActionCardMessage{
+		Title:    "t",
+		Markdown: "m",
+		Buttons:  []ActionCardButton{{Title: "yes", ActionURL: "u1"}, {Title: "no", ActionURL: "u2"}},
+	}
+	if err := client.SendRobotMessage("robot", msg); err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"msgtype":"actionCard","actionCard":{"title":"t","text":"m",
+		"btns":[{"title":"yes","actionURL":"u1"},{"title":"no","actionURL":"u2"}]}}`), &expected)
+	if !reflect.DeepEqual(bodies[0], expected) {
+		t.Errorf("got %v, expected %v", bodies[0], expected)
+	}
+}
+
+func TestSendWorkNotice(t *testing.T) {
+	var bodies []map[string]interface{}
+	client := captureRequests(&bodies)
+	client.AgentID = "default"
+	if err := client.SendWorkNotice("", "u1|u2", MarkdownMessage{Title: "t", Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[0]["agentid"] != "default" || bodies[0]["touser"] != "u1|u2" || bodies[0]["msgtype"] != "markdown" {
+		t.Errorf("unexpected request %v", bodies[0])
+	}
+}
@@ -0,0 +1,126 @@
+package godingtalk
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Option configures a DingTalkClient created by NewClient
+type Option func(*DingTalkClient)
+
+//WithBaseURL sets the address of DingTalk Open API, e.g. to use a test server
+func WithBaseURL(baseURL string) Option {
+	return func(c *DingTalkClient) {
+		if !strings.HasSuffix(baseURL, "/") {
+			baseURL += "/"
+		}
+		c.BaseURL = baseURL
+	}
+}
+
+//WithTaobaoURL sets the address of the Taobao gateway used by the external contact APIs
+func WithTaobaoURL(taobaoURL string) Option {
+	return func(c *DingTalkClient) {
+		c.TaobaoURL = taobaoURL
+	}
+}
+
+//WithHTTPClient sets the HTTP client used for all requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *DingTalkClient) {
+		c.HTTPClient = client
+	}
+}
+
+//WithCache sets where the access token is cached
+func WithCache(cache Cache) Option {
+	return func(c *DingTalkClient) {
+		c.Cache = cache
+	}
+}
+
+//WithTicketCache sets where the JS API ticket is cached, which defaults to the access token's cache
+func WithTicketCache(cache Cache) Option {
+	return func(c *DingTalkClient) {
+		c.TicketCache = cache
+	}
+}
+
+//WithLogger sets the logger which receives the client's diagnostic logs
+func WithLogger(logger Logger) Option {
+	return func(c *DingTalkClient) {
+		c.Logger = logger
+	}
+}
+
+//WithAgentID sets the default agent used to send app messages
+func WithAgentID(agentID string) Option {
+	return func(c *DingTalkClient) {
+		c.AgentID = agentID
+	}
+}
+
+//WithUserAgent sets the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *DingTalkClient) {
+		c.UserAgent = userAgent
+	}
+}
+
+//WithRetryPolicy sets how transient failures are retried
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *DingTalkClient) {
+		c.RetryPolicy = policy
+	}
+}
+
+//WithRateLimiter sets the limiter which delays requests to stay within DingTalk's quotas
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *DingTalkClient) {
+		c.RateLimiter = limiter
+	}
+}
+
+//NewClient creates a DingTalkClient for the given corp. Unless configured
+//otherwise by opts, the client caches its access token in memory
+func NewClient(corpID string, corpSecret string, opts ...Option) *DingTalkClient {
+	c := &DingTalkClient{
+		CorpID:     corpID,
+		CorpSecret: corpSecret,
+		BaseURL:    BASE_URL,
+		TaobaoURL:  TAOBAO_BASE_URL,
+		UserAgent:  "godingtalk/" + VERSION,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		Cache:   NewInMemoryCache(),
+		RWMutex: &sync.RWMutex{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *DingTalkClient) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return BASE_URL
+}
+
+func (c *DingTalkClient) taobaoURL() string {
+	if c.TaobaoURL != "" {
+		return c.TaobaoURL
+	}
+	return TAOBAO_BASE_URL
+}
+
+func (c *DingTalkClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
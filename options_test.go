@@ -0,0 +1,104 @@
+package godingtalk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewClientOptions(t *testing.T) {
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.Header().Set("Content-Type", typeJSON)
+		switch r.URL.Path {
+		case "/gettoken":
+			if r.URL.Query().Get("corpid") != "corp" || r.URL.Query().Get("corpsecret") != "secret" {
+				t.Errorf("unexpected credentials %v", r.URL.Query())
+			}
+			w.Write([]byte(`{"errcode":0,"access_token":"token","expires_in":7200}`))
+		case "/user/get":
+			w.Write([]byte(`{"errcode":0,"userid":"u1","name":"Hugo"}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("corp", "secret",
+		WithBaseURL(srv.URL),
+		WithHTTPClient(srv.Client()),
+		WithAgentID("agent"),
+		WithUserAgent("test-agent"),
+		WithRetryPolicy(DefaultRetryPolicy()),
+	)
+	if client.AgentID != "agent" || client.RetryPolicy == nil {
+		t.Errorf("options not applied: %+v", client)
+	}
+	if _, ok := client.Cache.(*InMemoryCache); !ok {
+		t.Errorf("NewClient should not share a file cache by default, got %T", client.Cache)
+	}
+
+	user, err := client.UserDetail("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Hugo" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if userAgent != "test-agent" {
+		t.Errorf("unexpected User-Agent %q", userAgent)
+	}
+}
+
+func TestClientsShareCacheWithoutClobbering(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", typeJSON)
+		w.Write([]byte(`{"errcode":0,"access_token":"token-of-` + r.URL.Query().Get("corpid") + `","expires_in":7200}`))
+	}))
+	defer srv.Close()
+
+	cache := NewInMemoryCache()
+	a := NewClient("a", "secret", WithBaseURL(srv.URL), WithCache(cache))
+	b := NewClient("b", "secret", WithBaseURL(srv.URL), WithCache(cache))
+	if err := a.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	a2 := NewClient("a", "secret", WithBaseURL("http://127.0.0.1:1"), WithCache(cache))
+	if err := a2.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if a2.AccessToken != "token-of-a" || b.AccessToken != "token-of-b" {
+		t.Errorf("tokens clobbered: a=%q b=%q", a2.AccessToken, b.AccessToken)
+	}
+}
+
+func TestAppsOfOneCorpShareCacheWithoutClobbering(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", typeJSON)
+		w.Write([]byte(`{"errcode":0,"access_token":"token-of-` + r.URL.Query().Get("corpsecret") + `","expires_in":7200}`))
+	}))
+	defer srv.Close()
+
+	cache := NewInMemoryCache()
+	app := NewClient("corp", "app", WithBaseURL(srv.URL), WithCache(cache))
+	suite := NewClient("corp", "suite", WithBaseURL(srv.URL), WithCache(cache))
+	if err := app.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if err := suite.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	app2 := NewClient("corp", "app", WithBaseURL("http://127.0.0.1:1"), WithCache(cache))
+	if err := app2.RefreshAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if app2.AccessToken != "token-of-app" || suite.AccessToken != "token-of-suite" {
+		t.Errorf("tokens clobbered: app=%q suite=%q", app2.AccessToken, suite.AccessToken)
+	}
+}
@@ -0,0 +1,138 @@
+package godingtalk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//RateLimiter is a token bucket limiter which delays requests to stay within
+//DingTalk's QPS quotas. It limits all requests of a client and, optionally,
+//each API path separately
+type RateLimiter struct {
+	// OnWait is called after a request had to wait for the limiter
+	OnWait func(path string, wait time.Duration)
+
+	mu     sync.Mutex
+	global *tokenBucket
+	paths  map[string]*tokenBucket
+	stats  RateLimiterStats
+}
+
+//RateLimiterStats is the wait time accounting of a RateLimiter
+type RateLimiterStats struct {
+	Requests  int64         // requests which passed the limiter
+	Delayed   int64         // requests which had to wait
+	TotalWait time.Duration // sum of all waits
+	MaxWait   time.Duration // longest single wait
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+//reserve takes a token and returns how long to wait until it is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+//NewRateLimiter creates a limiter allowing qps requests per second with bursts of
+//up to burst requests. A qps of 0 or less leaves the client without an overall
+//limit, so that only the per path limits set by SetLimit apply
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	l := &RateLimiter{
+		paths: map[string]*tokenBucket{},
+	}
+	if qps > 0 {
+		l.global = newTokenBucket(qps, burst)
+	}
+	return l
+}
+
+//SetLimit limits the requests to a single API path such as "user/get", in addition to the overall limit
+func (l *RateLimiter) SetLimit(path string, qps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if qps > 0 {
+		l.paths[path] = newTokenBucket(qps, burst)
+	} else {
+		delete(l.paths, path)
+	}
+}
+
+//Wait blocks until a request to path is allowed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, path string) error {
+	l.mu.Lock()
+	now := time.Now()
+	var buckets []*tokenBucket
+	var wait time.Duration
+	for _, b := range []*tokenBucket{l.global, l.paths[path]} {
+		if b == nil {
+			continue
+		}
+		buckets = append(buckets, b)
+		if d := b.reserve(now); d > wait {
+			wait = d
+		}
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		if err := sleep(ctx, wait); err != nil {
+			l.mu.Lock()
+			for _, b := range buckets {
+				b.tokens++
+			}
+			l.mu.Unlock()
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	l.stats.Requests++
+	if wait > 0 {
+		l.stats.Delayed++
+		l.stats.TotalWait += wait
+		if wait > l.stats.MaxWait {
+			l.stats.MaxWait = wait
+		}
+	}
+	l.mu.Unlock()
+
+	if wait > 0 && l.OnWait != nil {
+		l.OnWait(path, wait)
+	}
+	return nil
+}
+
+//Stats returns a snapshot of the limiter's wait time accounting
+func (l *RateLimiter) Stats() RateLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
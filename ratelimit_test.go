@@ -0,0 +1,70 @@
+package godingtalk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterDelaysBurst(t *testing.T) {
+	l := NewRateLimiter(50, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background(), "user/get"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 requests at 50 qps took only %v", elapsed)
+	}
+	stats := l.Stats()
+	if stats.Requests != 3 || stats.Delayed != 2 || stats.TotalWait <= 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRateLimiterPerPath(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	l.SetLimit("chat/send", 1, 1)
+	var waited []string
+	l.OnWait = func(path string, wait time.Duration) {
+		waited = append(waited, path)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(ctx, "user/get"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Wait(ctx, "chat/send"); err != nil {
+		t.Fatal(err)
+	}
+	if len(waited) != 0 {
+		t.Errorf("no request should have waited, got %v", waited)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "chat/send"); err == nil {
+		t.Error("expected the second chat/send to give up when the context expires")
+	}
+}
+
+func TestClientUsesRateLimiter(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	client.RateLimiter = NewRateLimiter(0, 0)
+	client.RateLimiter.SetLimit("user/get", 50, 1)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.UserDetail("user"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := client.RateLimiter.Stats(); stats.Requests != 2 || stats.Delayed != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,233 @@
+package godingtalk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//RedisStore is a CASStore speaking the Redis protocol over a single connection.
+//It only implements the few commands needed to share tokens and tickets
+type RedisStore struct {
+	Addr        string
+	Password    string
+	DB          int
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+//RedisError is an error reply from the Redis server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+//NewRedisStore creates a RedisStore for the server at addr, e.g. "127.0.0.1:6379"
+func NewRedisStore(addr string, password string, db int) *RedisStore {
+	return &RedisStore{
+		Addr:        addr,
+		Password:    password,
+		DB:          db,
+		DialTimeout: 5 * time.Second,
+		IOTimeout:   5 * time.Second,
+	}
+}
+
+//casScript sets KEYS[1] to ARGV[3] only if it holds ARGV[2], or holds nothing when ARGV[1] is "nil"
+const casScript = `
+local cur = redis.call('GET', KEYS[1])
+if (ARGV[1] == 'nil' and cur == false) or (ARGV[1] == 'val' and cur == ARGV[2]) then
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+	return 1
+end
+return 0`
+
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	reply, err := s.do("GET", []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrCacheMiss
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	return value, nil
+}
+
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := s.do("SET", []byte(key), value, []byte("PX"), []byte(strconv.FormatInt(ttlMillis(ttl), 10)))
+	return err
+}
+
+func (s *RedisStore) CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	expect := "val"
+	if old == nil {
+		expect = "nil"
+	}
+	reply, err := s.do("EVAL", []byte(casScript), []byte("1"), []byte(key),
+		[]byte(expect), old, value, []byte(strconv.FormatInt(ttlMillis(ttl), 10)))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+//Close closes the connection to the server
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func ttlMillis(ttl time.Duration) int64 {
+	if ms := ttl.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+//do sends a command and reads its reply, reconnecting once if the connection is broken
+func (s *RedisStore) do(args ...interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply, err := s.roundTrip(args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		s.closeLocked()
+		reply, err = s.roundTrip(args...)
+		if err != nil && !errors.As(err, &redisErr) {
+			s.closeLocked()
+		}
+	}
+	return reply, err
+}
+
+func (s *RedisStore) roundTrip(args ...interface{}) (interface{}, error) {
+	if err := s.connectLocked(); err != nil {
+		return nil, err
+	}
+	if s.IOTimeout > 0 {
+		s.conn.SetDeadline(time.Now().Add(s.IOTimeout))
+	}
+	if err := writeRedisCommand(s.conn, args...); err != nil {
+		return nil, err
+	}
+	return readRedisReply(s.rd)
+}
+
+func (s *RedisStore) connectLocked() error {
+	if s.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", s.Addr, s.DialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.rd = bufio.NewReader(conn)
+	if s.IOTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.IOTimeout))
+	}
+	if s.Password != "" {
+		if err = writeRedisCommand(conn, "AUTH", s.Password); err == nil {
+			_, err = readRedisReply(s.rd)
+		}
+	}
+	if err == nil && s.DB != 0 {
+		if err = writeRedisCommand(conn, "SELECT", strconv.Itoa(s.DB)); err == nil {
+			_, err = readRedisReply(s.rd)
+		}
+	}
+	if err != nil {
+		s.closeLocked()
+	}
+	return err
+}
+
+func (s *RedisStore) closeLocked() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func writeRedisCommand(w io.Writer, args ...interface{}) error {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			b = []byte(fmt.Sprint(v))
+		}
+		buf = append(buf, "$"+strconv.Itoa(len(b))+"\r\n"...)
+		buf = append(buf, b...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+//readRedisReply decodes a reply into nil, string, int64, []byte or []interface{}
+func readRedisReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, RedisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(rd, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
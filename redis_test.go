@@ -0,0 +1,159 @@
+package godingtalk
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeRedis is an in-process server for the commands RedisStore sends
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	mu       sync.Mutex
+	data     map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, password: password, data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readRedisReply(rd)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range reply.([]interface{}) {
+			args = append(args, string(a.([]byte)))
+		}
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			continue
+		}
+		f.mu.Lock()
+		switch cmd {
+		case "AUTH":
+			authed = args[1] == f.password
+			if authed {
+				conn.Write([]byte("+OK\r\n"))
+			} else {
+				conn.Write([]byte("-ERR invalid password\r\n"))
+			}
+		case "SELECT":
+			conn.Write([]byte("+OK\r\n"))
+		case "GET":
+			if v, ok := f.data[args[1]]; ok {
+				writeBulkReply(conn, v)
+			} else {
+				conn.Write([]byte("$-1\r\n"))
+			}
+		case "SET":
+			f.data[args[1]] = args[2]
+			conn.Write([]byte("+OK\r\n"))
+		case "EVAL":
+			key, expect, old, value := args[3], args[4], args[5], args[6]
+			cur, ok := f.data[key]
+			if (expect == "nil" && !ok) || (expect == "val" && ok && cur == old) {
+				f.data[key] = value
+				conn.Write([]byte(":1\r\n"))
+			} else {
+				conn.Write([]byte(":0\r\n"))
+			}
+		default:
+			conn.Write([]byte("-ERR unknown command\r\n"))
+		}
+		f.mu.Unlock()
+	}
+}
+
+func writeBulkReply(conn net.Conn, v string) {
+	conn.Write([]byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"))
+}
+
+func TestRedisStore(t *testing.T) {
+	srv := newFakeRedis(t, "pass")
+	store := NewRedisStore(srv.ln.Addr().String(), "pass", 1)
+	defer store.Close()
+
+	if _, err := store.Get("missing"); err != ErrCacheMiss {
+		t.Errorf("expected ErrCacheMiss, got %v", err)
+	}
+	if err := store.Set("k", []byte("v1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := store.Get("k"); err != nil || string(v) != "v1" {
+		t.Errorf("unexpected value %q %v", v, err)
+	}
+	if ok, err := store.CompareAndSwap("k", []byte("other"), []byte("v2"), time.Minute); ok || err != nil {
+		t.Errorf("swap with wrong old value should fail: %v %v", ok, err)
+	}
+	if ok, err := store.CompareAndSwap("k", []byte("v1"), []byte("v2"), time.Minute); !ok || err != nil {
+		t.Errorf("swap should succeed: %v %v", ok, err)
+	}
+	if ok, _ := store.CompareAndSwap("new", nil, []byte("v"), time.Minute); !ok {
+		t.Error("swap of an absent key should succeed")
+	}
+
+	bad := NewRedisStore(srv.ln.Addr().String(), "wrong", 0)
+	defer bad.Close()
+	if _, err := bad.Get("k"); err == nil {
+		t.Error("expected authentication error")
+	}
+}
+
+func TestKVCacheSharedAcrossInstances(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	instance1 := NewKVCache(NewRedisStore(srv.ln.Addr().String(), "", 0), "godingtalk:")
+	instance2 := NewKVCache(NewRedisStore(srv.ln.Addr().String(), "", 0), "godingtalk:")
+
+	now := time.Now().Unix()
+	token := AccessTokenResponse{AccessToken: "new", Expires: 7200, Created: now}
+	if err := instance1.Set("access_token:corp", &token); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AccessTokenResponse
+	if err := instance2.Get("access_token:corp", &got); err != nil || got.AccessToken != "new" {
+		t.Fatalf("expected the token from instance1, got %+v %v", got, err)
+	}
+
+	older := AccessTokenResponse{AccessToken: "old", Expires: 7200, Created: now - 60}
+	if err := instance2.Set("access_token:corp", &older); err != nil {
+		t.Fatal(err)
+	}
+	instance1.Get("access_token:corp", &got)
+	if got.AccessToken != "new" {
+		t.Errorf("an older token must not replace a newer one, got %q", got.AccessToken)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if _, ok := srv.data["godingtalk:access_token:corp"]; !ok {
+		t.Errorf("expected prefixed key, have %v", srv.data)
+	}
+}
@@ -0,0 +1,132 @@
+package godingtalk
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/url"
+	"time"
+)
+
+//DefaultRetryableErrCodes are the errcodes which DingTalk uses for transient failures
+var DefaultRetryableErrCodes = []int{ErrCodeSystemBusy, ErrCodeRateLimited, ErrCodeQPSLimited}
+
+//nonIdempotentPaths are the APIs which may act twice if a request is repeated
+//after DingTalk has already processed it
+var nonIdempotentPaths = map[string]bool{
+	"message/send":          true,
+	"chat/send":             true,
+	"chat/create":           true,
+	"robot/send":            true,
+	"media/upload":          true,
+	"dingtalk.corp.ext.add": true,
+
+	"topapi/message/corpconversation/asyncsend_v2": true,
+	"topapi/im/chat/interactivecards/send":         true,
+
+	// consuming reads: a repeated request returns the next events, losing the first ones
+	"call_back/get_call_back_failed_result": true,
+}
+
+//RetryPolicy controls how transient failures are retried by the transport
+type RetryPolicy struct {
+	MaxAttempts int           // attempts including the first one, 1 or less disables retries
+	BaseDelay   time.Duration // wait before the first retry, doubled for each further retry
+	MaxDelay    time.Duration // upper bound of a single wait
+	Jitter      float64       // fraction between 0 and 1 by which a wait is randomly shortened
+
+	RetryableErrCodes  []int // overrides DefaultRetryableErrCodes when not nil, HTTP 429 is retried along with ErrCodeRateLimited
+	RetryNonIdempotent bool  // also retry APIs such as message/send, which may then deliver twice
+}
+
+//DefaultRetryPolicy returns a policy suitable for most applications
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+//delay returns how long to wait before the given retry, counting from 1
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+func (p *RetryPolicy) isRetryableErrCode(code int) bool {
+	codes := p.RetryableErrCodes
+	if codes == nil {
+		codes = DefaultRetryableErrCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+//shouldRetry reports whether a failed request to path may be sent again
+func (p *RetryPolicy) shouldRetry(ctx context.Context, path string, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	// DingTalk did not process requests rejected by its rate limits,
+	// so they are safe to repeat even for non-idempotent APIs
+	if IsRateLimited(err) {
+		return p.isRetryableErrCode(rateLimitErrCode(err))
+	}
+	if nonIdempotentPaths[path] && !p.RetryNonIdempotent {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return p.isRetryableErrCode(apiErr.ErrCode)
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+//rateLimitErrCode is the errcode of a rate limited error. HTTP 429 and the limits
+//of the Taobao gateway count as ErrCodeRateLimited
+func rateLimitErrCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrCode
+	}
+	return ErrCodeRateLimited
+}
+
+//sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,135 @@
+package godingtalk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryTransientFailures(t *testing.T) {
+	attempts := 0
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		switch attempts {
+		case 1:
+			return jsonResponse(503, ``), nil
+		case 2:
+			return jsonResponse(200, `{"errcode":-1,"errmsg":"系统繁忙"}`), nil
+		default:
+			return jsonResponse(200, `{"errcode":0}`), nil
+		}
+	})
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	if _, err := client.DepartmentList(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	attempts := 0
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return jsonResponse(502, ``), nil
+	})
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	if err := client.SendAppMessage("1", "user", "hello"); err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Errorf("message/send must not be retried by default, got %d attempts", attempts)
+	}
+
+	attempts = 0
+	client.RetryPolicy.RetryNonIdempotent = true
+	client.SendAppMessage("1", "user", "hello")
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts with RetryNonIdempotent, got %d", attempts)
+	}
+}
+
+func TestRetryFailedCallbacksOnce(t *testing.T) {
+	attempts := 0
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return jsonResponse(502, ``), nil
+	})
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	client.GetFailedCallbacks()
+	if attempts != 1 {
+		t.Errorf("fetching failed callbacks consumes them and should not be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRetryRateLimitedNonIdempotent(t *testing.T) {
+	attempts := 0
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return jsonResponse(200, `{"errcode":90018,"errmsg":"qps limited"}`), nil
+		}
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	if err := client.SendAppMessage("1", "user", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("rejected requests are safe to retry, got %d attempts", attempts)
+	}
+}
+
+func TestRetryRateLimitedFollowsErrCodes(t *testing.T) {
+	for _, body := range []string{`{"errcode":88}`, `{"errcode":90018}`, ``} {
+		attempts := 0
+		client := newStubClient(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if body == "" {
+				return jsonResponse(429, ``), nil
+			}
+			return jsonResponse(200, body), nil
+		})
+		client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryableErrCodes: []int{ErrCodeSystemBusy}}
+
+		client.DepartmentList()
+		if attempts != 1 {
+			t.Errorf("%q: rate limits left out of RetryableErrCodes should not be retried, got %d attempts", body, attempts)
+		}
+	}
+}
+
+func TestRetryStopsWhenContextDone(t *testing.T) {
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(500, ``), nil
+	})
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.DepartmentListContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+	for retry := 1; retry < 10; retry++ {
+		d := p.delay(retry)
+		if d > time.Second || d < 50*time.Millisecond {
+			t.Errorf("delay(%d) = %v out of range", retry, d)
+		}
+	}
+	p.Jitter = 0
+	if d := p.delay(3); d != 400*time.Millisecond {
+		t.Errorf("delay(3) = %v, expected 400ms", d)
+	}
+}
@@ -0,0 +1,147 @@
+package godingtalk
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//DefaultRobotMaxClockSkew is how old the timestamp of a robot callback can be
+const DefaultRobotMaxClockSkew = time.Hour
+
+//RobotCallback is the message DingTalk posts to an outgoing robot (机器人回调) when it is @mentioned
+type RobotCallback struct {
+	MsgID   string `json:"msgId"`
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+	CreateAt int64 `json:"createAt"` // 毫秒
+
+	ConversationID    string `json:"conversationId"`
+	ConversationType  string `json:"conversationType"` // "1" 单聊, "2" 群聊
+	ConversationTitle string `json:"conversationTitle"`
+
+	ChatbotCorpID string `json:"chatbotCorpId"`
+	ChatbotUserID string `json:"chatbotUserId"`
+
+	SenderID      string `json:"senderId"`
+	SenderNick    string `json:"senderNick"`
+	SenderCorpID  string `json:"senderCorpId"`
+	SenderStaffID string `json:"senderStaffId"` // 仅企业内部机器人可获得
+	IsAdmin       bool   `json:"isAdmin"`
+
+	AtUsers    []RobotAtUser `json:"atUsers"`
+	IsInAtList bool          `json:"isInAtList"`
+
+	SessionWebhook            string `json:"sessionWebhook"`
+	SessionWebhookExpiredTime int64  `json:"sessionWebhookExpiredTime"` // 毫秒
+}
+
+//RobotAtUser is a user @mentioned in a RobotCallback
+type RobotAtUser struct {
+	DingtalkID string `json:"dingtalkId"`
+	StaffID    string `json:"staffId"`
+}
+
+//Reply returns a Robot which sends messages to the conversation of the callback
+//through its session webhook, for as long as the webhook has not expired
+func (m *RobotCallback) Reply() *Robot {
+	return NewRobot(m.SessionWebhook, "")
+}
+
+//RobotHandlerFunc handles a RobotCallback. The returned message, if not nil, is the
+//synchronous reply. To reply later, return nil and send through m.Reply() instead,
+//with a context other than ctx, which is done once the callback is answered
+type RobotHandlerFunc func(ctx context.Context, m *RobotCallback) (Message, error)
+
+//RobotHandler is an http.Handler for the callbacks of an outgoing robot
+type RobotHandler struct {
+	AppSecret    string
+	Handler      RobotHandlerFunc
+	MaxClockSkew time.Duration // 为0时使用 DefaultRobotMaxClockSkew
+	Logger       Logger
+}
+
+//NewRobotHandler creates a RobotHandler verifying callbacks with the AppSecret of the robot
+func NewRobotHandler(appSecret string, handler RobotHandlerFunc) *RobotHandler {
+	return &RobotHandler{
+		AppSecret: appSecret,
+		Handler:   handler,
+	}
+}
+
+func (h *RobotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.verify(r.Header.Get("timestamp"), r.Header.Get("sign"), time.Now()); err != nil {
+		h.log(ctx, slog.LevelWarn, "dingtalk robot callback rejected", "error", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var m RobotCallback
+	if !decodeCallbackBody(w, r, &m, "invalid robot callback") {
+		return
+	}
+	reply, err := h.Handler(ctx, &m)
+	if err != nil {
+		h.log(ctx, slog.LevelError, "dingtalk robot handler failed", "msg_id", m.MsgID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", typeJSON+"; charset=UTF-8")
+	if reply == nil {
+		w.Write([]byte("{}"))
+		return
+	}
+	json.NewEncoder(w).Encode(robotMessagePayload(reply))
+}
+
+//verify checks the sign of a callback, and that its timestamp is recent enough
+func (h *RobotHandler) verify(timestamp string, sign string, now time.Time) error {
+	return verifyTimestampSign(timestamp, sign, h.AppSecret, h.MaxClockSkew, now)
+}
+
+//verifyTimestampSign checks a sign made by robotSign, as DingTalk signs the callbacks of
+//robots and cards, and that the timestamp in milliseconds is within skew of now
+func verifyTimestampSign(timestamp string, sign string, secret string, skew time.Duration, now time.Time) error {
+	if timestamp == "" || sign == "" {
+		return errRobotUnsigned
+	}
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errRobotStale
+	}
+	if skew <= 0 {
+		skew = DefaultRobotMaxClockSkew
+	}
+	sent := time.Unix(0, ms*int64(time.Millisecond))
+	if now.Sub(sent) > skew || sent.Sub(now) > skew {
+		return errRobotStale
+	}
+	if !hmac.Equal([]byte(sign), []byte(robotSign(timestamp, secret))) {
+		return errRobotSign
+	}
+	return nil
+}
+
+func (h *RobotHandler) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Log(ctx, level, msg, args...)
+	}
+}
+
+//Errors rejecting a signed callback
+var (
+	errRobotUnsigned = errors.New("callback is not signed")
+	errRobotStale    = errors.New("callback timestamp is out of range")
+	errRobotSign     = errors.New("callback sign does not match")
+)
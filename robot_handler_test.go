@@ -0,0 +1,93 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRobotCallback(secret string, sent time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(sent.UnixNano()/int64(time.Millisecond), 10)
+	req := httptest.NewRequest("POST", "/robot", strings.NewReader(body))
+	req.Header.Set("timestamp", timestamp)
+	req.Header.Set("sign", robotSign(timestamp, secret))
+	return req
+}
+
+func TestRobotHandlerReply(t *testing.T) {
+	var got *RobotCallback
+	h := NewRobotHandler("secret", func(ctx context.Context, m *RobotCallback) (Message, error) {
+		got = m
+		return TextMessage{Content: "pong"}, nil
+	})
+	body := `{"msgtype":"text","text":{"content":" ping"},"senderNick":"nick","conversationType":"2",
+		"atUsers":[{"dingtalkId":"$:bot"}],"sessionWebhook":"https://oapi.dingtalk.com/robot/sendBySession?session=x"}`
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRobotCallback("secret", time.Now(), body))
+	if w.Code != 200 {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+	}
+	if got.Text.Content != " ping" || got.SenderNick != "nick" || got.AtUsers[0].DingtalkID != "$:bot" {
+		t.Errorf("unexpected callback %+v", got)
+	}
+	if robot := got.Reply(); robot.Webhook != got.SessionWebhook {
+		t.Errorf("reply robot should use the session webhook, got %s", robot.Webhook)
+	}
+	var reply map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &reply)
+	if reply["msgtype"] != "text" || reply["text"].(map[string]interface{})["content"] != "pong" {
+		t.Errorf("unexpected reply %s", w.Body)
+	}
+}
+
+func TestRobotHandlerRejects(t *testing.T) {
+	called := false
+	h := NewRobotHandler("secret", func(ctx context.Context, m *RobotCallback) (Message, error) {
+		called = true
+		return nil, nil
+	})
+	cases := map[string]*http.Request{
+		"unsigned":   httptest.NewRequest("POST", "/robot", strings.NewReader("{}")),
+		"wrong sign": signedRobotCallback("other", time.Now(), "{}"),
+		"stale":      signedRobotCallback("secret", time.Now().Add(-2*time.Hour), "{}"),
+	}
+	for name, req := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+	}
+	if called {
+		t.Error("handler must not be called for rejected callbacks")
+	}
+}
+
+func TestRobotHandlerTooLarge(t *testing.T) {
+	h := NewRobotHandler("secret", func(ctx context.Context, m *RobotCallback) (Message, error) {
+		return nil, nil
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRobotCallback("secret", time.Now(), `{"msgId":"`+strings.Repeat("a", MaxCallbackBodySize)+`"}`))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+}
+
+func TestRobotHandlerError(t *testing.T) {
+	h := NewRobotHandler("secret", func(ctx context.Context, m *RobotCallback) (Message, error) {
+		return nil, errors.New("boom")
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRobotCallback("secret", time.Now(), "{}"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,301 @@
+package godingtalk
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//RobotMessagesPerMinute is how many messages DingTalk accepts from a robot each minute
+const RobotMessagesPerMinute = 20
+
+//DefaultRobotQueueMaxPending is how many messages a RobotQueue keeps by default
+const DefaultRobotQueueMaxPending = 1000
+
+//RobotQueue sends messages through a Robot without exceeding its budget of messages
+//per minute. When more messages are pending than the budget allows, pending text
+//messages are merged into a single markdown digest. Nothing is persisted: messages
+//still pending when the queue stops are lost, unless saved from the hooks
+type RobotQueue struct {
+	Robot       *Robot
+	PerMinute   int    // 为0时使用 RobotMessagesPerMinute
+	MaxPending  int    // 为0时使用 DefaultRobotQueueMaxPending, 超出时丢弃最早的消息
+	DigestTitle string // 合并后的markdown消息的标题
+
+	// OnSend is called after each message is sent, with the error of the send
+	OnSend func(msg Message, err error)
+	// OnDrop is called for each message dropped because too many are pending
+	OnDrop func(msg Message)
+	// OnMerge is called when pending text messages are merged into digest
+	OnMerge func(merged []Message, digest Message)
+
+	mu      sync.Mutex
+	pending []robotQueueItem
+	window  []time.Time // send times within the last minute
+	stats   RobotQueueStats
+	wake    chan struct{}
+
+	sendMu sync.Mutex
+}
+
+//RobotQueueStats counts what a RobotQueue did with its messages
+type RobotQueueStats struct {
+	Sent    int64 // messages sent successfully, digests included
+	Failed  int64 // sends which returned an error, not counted in Sent
+	Dropped int64 // messages dropped because too many were pending
+	Merged  int64 // text messages merged into digests
+}
+
+type robotQueueItem struct {
+	msg Message
+	at  *RobotAt
+}
+
+//NewRobotQueue creates a RobotQueue sending through robot. Call Start, or Flush,
+//to send the queued messages
+func NewRobotQueue(robot *Robot) *RobotQueue {
+	return &RobotQueue{
+		Robot: robot,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+//wakeChan returns the channel waking up the goroutine of Start, creating it for
+//queues which were not made by NewRobotQueue
+func (q *RobotQueue) wakeChan() chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.wake == nil {
+		q.wake = make(chan struct{}, 1)
+	}
+	return q.wake
+}
+
+//Push queues msg, mentioning the users in at if it is not nil. It never blocks
+func (q *RobotQueue) Push(msg Message, at *RobotAt) {
+	var dropped []Message
+	q.mu.Lock()
+	q.pending = append(q.pending, robotQueueItem{msg: msg, at: at})
+	if extra := len(q.pending) - q.maxPending(); extra > 0 {
+		for _, item := range q.pending[:extra] {
+			dropped = append(dropped, item.msg)
+		}
+		q.pending = append([]robotQueueItem(nil), q.pending[extra:]...)
+		q.stats.Dropped += int64(extra)
+	}
+	q.mu.Unlock()
+
+	if q.OnDrop != nil {
+		for _, m := range dropped {
+			q.OnDrop(m)
+		}
+	}
+	select {
+	case q.wakeChan() <- struct{}{}:
+	default:
+	}
+}
+
+//Len returns how many messages are pending
+func (q *RobotQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+//Stats returns a snapshot of the queue's counters
+func (q *RobotQueue) Stats() RobotQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
+
+//Start sends queued messages in the background as the budget allows. It stops when ctx is done
+func (q *RobotQueue) Start(ctx context.Context) {
+	wake := q.wakeChan()
+	go func() {
+		for {
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+			for {
+				sent, err := q.sendNext(ctx)
+				if err != nil || !sent {
+					break
+				}
+			}
+		}
+	}()
+}
+
+//Flush sends all pending messages, waiting for the budget as needed, and returns
+//early with the error of ctx if it is done first
+func (q *RobotQueue) Flush(ctx context.Context) error {
+	for {
+		sent, err := q.sendNext(ctx)
+		if err != nil {
+			return err
+		}
+		if !sent {
+			return nil
+		}
+	}
+}
+
+//sendNext waits for the budget and sends the next pending message, if any
+func (q *RobotQueue) sendNext(ctx context.Context) (bool, error) {
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	if q.Len() == 0 {
+		return false, nil
+	}
+	remaining, err := q.waitBudget(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	q.mu.Lock()
+	if len(q.pending) == 0 {
+		q.mu.Unlock()
+		return false, nil
+	}
+	var merged []Message
+	var digest Message
+	if len(q.pending) > remaining {
+		merged, digest = q.mergeTextsLocked()
+	}
+	item := q.pending[0]
+	q.pending = q.pending[1:]
+	q.window = append(q.window, time.Now())
+	q.mu.Unlock()
+
+	if merged != nil && q.OnMerge != nil {
+		q.OnMerge(merged, digest)
+	}
+	err = q.Robot.SendContext(ctx, item.msg, item.at)
+
+	q.mu.Lock()
+	if err != nil {
+		q.stats.Failed++
+	} else {
+		q.stats.Sent++
+	}
+	q.mu.Unlock()
+	if q.OnSend != nil {
+		q.OnSend(item.msg, err)
+	}
+	return true, nil
+}
+
+//waitBudget blocks until a message can be sent, and returns how many can be sent
+//right now, including that one
+func (q *RobotQueue) waitBudget(ctx context.Context) (int, error) {
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		i := 0
+		for i < len(q.window) && now.Sub(q.window[i]) >= time.Minute {
+			i++
+		}
+		q.window = q.window[i:]
+		remaining := q.perMinute() - len(q.window)
+		var wait time.Duration
+		if remaining <= 0 {
+			wait = time.Minute - now.Sub(q.window[0])
+		}
+		q.mu.Unlock()
+
+		if remaining > 0 {
+			return remaining, nil
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+}
+
+//mergeTextsLocked replaces the pending text messages by a markdown digest, in the
+//place of the first of them, and returns the merged messages and the digest
+func (q *RobotQueue) mergeTextsLocked() ([]Message, Message) {
+	var merged []Message
+	var lines []string
+	var at *RobotAt
+	digestAt := -1
+	rest := q.pending[:0:0]
+	for _, item := range q.pending {
+		text, ok := item.msg.(TextMessage)
+		if !ok {
+			rest = append(rest, item)
+			continue
+		}
+		if digestAt < 0 {
+			digestAt = len(rest)
+			rest = append(rest, robotQueueItem{})
+		}
+		merged = append(merged, item.msg)
+		lines = append(lines, "- "+strings.Replace(text.Content, "\n", "\n  ", -1))
+		at = mergeRobotAt(at, item.at)
+	}
+	if len(merged) < 2 {
+		return nil, nil
+	}
+
+	title := q.DigestTitle
+	if title == "" {
+		title = strconv.Itoa(len(merged)) + "条消息"
+	}
+	digest := MarkdownMessage{Title: title, Text: "#### " + title + "\n\n" + strings.Join(lines, "\n")}
+	rest[digestAt] = robotQueueItem{msg: digest, at: at}
+	q.pending = rest
+	q.stats.Merged += int64(len(merged))
+	return merged, digest
+}
+
+func mergeRobotAt(a *RobotAt, b *RobotAt) *RobotAt {
+	if b == nil {
+		return a
+	}
+	if a == nil {
+		a = &RobotAt{}
+	}
+	return &RobotAt{
+		AtMobiles: appendMissing(a.AtMobiles, b.AtMobiles),
+		AtUserIDs: appendMissing(a.AtUserIDs, b.AtUserIDs),
+		IsAtAll:   a.IsAtAll || b.IsAtAll,
+	}
+}
+
+func appendMissing(list []string, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, s := range list {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (q *RobotQueue) perMinute() int {
+	if q.PerMinute > 0 {
+		return q.PerMinute
+	}
+	return RobotMessagesPerMinute
+}
+
+func (q *RobotQueue) maxPending() int {
+	if q.MaxPending > 0 {
+		return q.MaxPending
+	}
+	return DefaultRobotQueueMaxPending
+}
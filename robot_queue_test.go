@@ -0,0 +1,156 @@
+package godingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//robotServer records the bodies posted to a robot webhook
+func robotServer(t *testing.T) (*Robot, func() []map[string]interface{}) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	t.Cleanup(srv.Close)
+	return NewRobot(srv.URL, ""), func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), bodies...)
+	}
+}
+
+func TestRobotQueueMergesOverBudget(t *testing.T) {
+	robot, bodies := robotServer(t)
+	q := NewRobotQueue(robot)
+	q.PerMinute = 3
+	var merged []Message
+	var digest Message
+	q.OnMerge = func(m []Message, d Message) { merged, digest = m, d }
+
+	q.Push(LinkMessage{Title: "dashboard"}, nil)
+	q.Push(TextMessage{Content: "disk full"}, &RobotAt{AtMobiles: []string{"1"}})
+	q.Push(LinkMessage{Title: "logs"}, nil)
+	q.Push(TextMessage{Content: "cpu high"}, &RobotAt{AtMobiles: []string{"1", "2"}})
+	q.Push(TextMessage{Content: "oom"}, nil)
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := bodies()
+	if len(sent) != 3 || sent[0]["msgtype"] != "link" || sent[1]["msgtype"] != "markdown" || sent[2]["msgtype"] != "link" {
+		t.Fatalf("expected the digest in place of the first text, got %v", sent)
+	}
+	text := sent[1]["markdown"].(map[string]interface{})["text"].(string)
+	if !strings.Contains(text, "- disk full\n- cpu high\n- oom") {
+		t.Errorf("unexpected digest %q", text)
+	}
+	if mobiles := sent[1]["at"].(map[string]interface{})["atMobiles"].([]interface{}); len(mobiles) != 2 {
+		t.Errorf("expected merged mentions, got %v", mobiles)
+	}
+	if len(merged) != 3 {
+		t.Errorf("OnMerge got %v", merged)
+	}
+	if md, ok := digest.(MarkdownMessage); !ok || md.Text != text {
+		t.Errorf("OnMerge should get the digest, got %#v", digest)
+	}
+	if stats := q.Stats(); stats.Sent != 3 || stats.Merged != 3 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRobotQueueWaitsForBudget(t *testing.T) {
+	robot, bodies := robotServer(t)
+	q := NewRobotQueue(robot)
+	q.PerMinute = 1
+
+	q.Push(TextMessage{Content: "1"}, nil)
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	q.Push(TextMessage{Content: "2"}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected to wait for the budget, got %v", err)
+	}
+	if len(bodies()) != 1 || q.Len() != 1 {
+		t.Errorf("the second message should still be pending, sent %v", bodies())
+	}
+}
+
+func TestRobotQueueDropsOldest(t *testing.T) {
+	robot, bodies := robotServer(t)
+	q := NewRobotQueue(robot)
+	q.MaxPending = 2
+	var dropped []Message
+	q.OnDrop = func(m Message) { dropped = append(dropped, m) }
+
+	for _, s := range []string{"1", "2", "3"} {
+		q.Push(TextMessage{Content: s}, nil)
+	}
+	if len(dropped) != 1 || dropped[0].(TextMessage).Content != "1" {
+		t.Errorf("expected the oldest message to be dropped, got %v", dropped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+	for i := 0; i < 100 && len(bodies()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sent := bodies(); len(sent) != 2 {
+		t.Errorf("expected the background sender to send 2 messages, got %v", sent)
+	}
+	if stats := q.Stats(); stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRobotQueueLiteralStarts(t *testing.T) {
+	robot, bodies := robotServer(t)
+	q := &RobotQueue{Robot: robot}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	q.Push(TextMessage{Content: "hello"}, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(bodies()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(bodies()) != 1 {
+		t.Fatal("a queue made without NewRobotQueue should send too")
+	}
+}
+
+func TestRobotQueueCountsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errcode":310000,"errmsg":"keywords not in content"}`))
+	}))
+	defer srv.Close()
+	q := NewRobotQueue(NewRobot(srv.URL, ""))
+
+	q.Push(TextMessage{Content: "hello"}, nil)
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.Stats(); stats.Sent != 0 || stats.Failed != 1 {
+		t.Errorf("failed sends should not count as sent, got %+v", stats)
+	}
+}
@@ -0,0 +1,52 @@
+package godingtalk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//flightGroup deduplicates concurrent calls with the same key, so that only one
+//of them does the work and the others wait for its result
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+//do runs fn unless a call with the same key is already in flight, and waits for
+//that call, or for ctx to be done, and returns its error. fn runs in the background
+//with a context detached from the callers, bounded by timeout, so that one caller
+//giving up does not fail the others waiting on the same call
+func (g *flightGroup) do(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			fnCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+			defer cancel()
+			call.err = fn(fnCtx)
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
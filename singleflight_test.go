@@ -0,0 +1,111 @@
+package godingtalk
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentCallsShareOneTokenRefresh(t *testing.T) {
+	var gettoken int32
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/gettoken" {
+			atomic.AddInt32(&gettoken, 1)
+			time.Sleep(20 * time.Millisecond)
+			return jsonResponse(200, `{"errcode":0,"access_token":"fresh","expires_in":7200}`), nil
+		}
+		if r.URL.Query().Get("access_token") != "fresh" {
+			return jsonResponse(200, `{"errcode":40014,"errmsg":"invalid token"}`), nil
+		}
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	client.AccessToken = ""
+	client.CorpSecret = "secret"
+	client.Cache = NewInMemoryCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.DepartmentList(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&gettoken); n != 1 {
+		t.Errorf("expected a single gettoken call, got %d", n)
+	}
+}
+
+func TestTokenRefresherRenewsBeforeExpiry(t *testing.T) {
+	var gettoken int32
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&gettoken, 1)
+		return jsonResponse(200, `{"errcode":0,"access_token":"renewed","expires_in":7200}`), nil
+	})
+	client.CorpSecret = "secret"
+	client.Cache = NewInMemoryCache()
+	client.setAccessToken("old", time.Now().Unix(), 61)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.StartTokenRefresher(ctx, 5*time.Second)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		token, _ := client.accessToken(ctx)
+		if token == "renewed" {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if token, _ := client.accessToken(ctx); token != "renewed" {
+		t.Fatalf("token was not renewed in the background, got %q", token)
+	}
+	if n := atomic.LoadInt32(&gettoken); n != 1 {
+		t.Errorf("expected one background refresh, got %d", n)
+	}
+}
+
+func TestTokenRefreshSurvivesFirstCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/gettoken" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+			return jsonResponse(200, `{"errcode":0,"access_token":"fresh","expires_in":7200}`), nil
+		}
+		return jsonResponse(200, `{"errcode":0}`), nil
+	})
+	client.AccessToken = ""
+	client.CorpSecret = "secret"
+	client.Cache = NewInMemoryCache()
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() { firstErr <- client.RefreshAccessTokenContext(first) }()
+	time.Sleep(10 * time.Millisecond)
+	secondErr := make(chan error, 1)
+	go func() { secondErr <- client.RefreshAccessTokenContext(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("the cancelled caller should give up, got %v", err)
+	}
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Errorf("the other caller should get the token, got %v", err)
+	}
+	if token, _ := client.accessToken(context.Background()); token != "fresh" {
+		t.Errorf("expected the shared refresh to finish, got %q", token)
+	}
+}
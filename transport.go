@@ -2,16 +2,17 @@ package godingtalk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
-	"fmt"
 )
 
 const typeJSON = "application/json"
@@ -32,104 +33,235 @@ type DownloadFile struct {
 	Reader   io.Reader
 }
 
-func (c *DingTalkClient) httpRPC(path string, params url.Values, requestData interface{}, responseData Unmarshallable) error {
+//requestBody is an encoded request which can be sent more than once
+type requestBody struct {
+	method      string
+	contentType string
+	data        []byte
+}
+
+//tokenlessPaths are the APIs which must be called without the corp access token
+var tokenlessPaths = map[string]bool{
+	"gettoken":     true,
+	"sns/gettoken": true,
+}
+
+func newRequestBody(requestData interface{}) (*requestBody, error) {
+	if requestData == nil {
+		return &requestBody{method: "GET"}, nil
+	}
+	switch requestData.(type) {
+	case UploadFile:
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+
+		uploadFile := requestData.(UploadFile)
+		if uploadFile.Reader == nil {
+			return nil, errors.New("upload file is empty")
+		}
+		fw, err := w.CreateFormFile(uploadFile.FieldName, uploadFile.FileName)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.Copy(fw, uploadFile.Reader); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+		return &requestBody{method: "POST", contentType: w.FormDataContentType(), data: b.Bytes()}, nil
+	default:
+		d, err := json.Marshal(requestData)
+		if err != nil {
+			return nil, err
+		}
+		return &requestBody{method: "POST", contentType: typeJSON, data: d}, nil
+	}
+}
+
+//withAccessToken calls fn with a valid access token, and calls it once more with
+//a fresh token if DingTalk rejects the first one as invalid or expired
+func (c *DingTalkClient) withAccessToken(ctx context.Context, fn func(token string) error) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(token)
+	if !IsTokenExpired(err) || c.CorpSecret == "" {
+		return err
+	}
+	if refreshErr := c.refreshAccessToken(ctx, token); refreshErr != nil {
+		return refreshErr
+	}
+	token, err = c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+func (c *DingTalkClient) httpRPC(ctx context.Context, path string, params url.Values, requestData interface{}, responseData Unmarshallable) error {
 	if params == nil {
 		params = url.Values{}
 	}
-	if c.AccessToken != "" && params.Get("access_token") == "" {
-		params.Set("access_token", c.AccessToken)}
-	return c.httpRequest(path, params, requestData, responseData)
+	body, err := newRequestBody(requestData)
+	if err != nil {
+		return err
+	}
+	if tokenlessPaths[path] || params.Get("access_token") != "" {
+		return c.doRequest(ctx, path, params, body, responseData)
+	}
+	return c.withAccessToken(ctx, func(token string) error {
+		params.Set("access_token", token)
+		return c.doRequest(ctx, path, params, body, responseData)
+	})
 }
 
-func (c *DingTalkClient) httpTaobaoRPC(method string, params url.Values, responseData Unmarshallable) error {
+func (c *DingTalkClient) httpTaobaoRPC(ctx context.Context, method string, params url.Values, responseData Unmarshallable) error {
 	if params == nil {
 		params = url.Values{}
 	}
 	params.Set("format", "json")
 	params.Set("method", method)
 	params.Set("partner_id", "apidoc")
-	params.Set("session", c.AccessToken)
-	params.Set("timestamp", time.Now().Format("2006-01-02 15:04:05"))
 	params.Set("v", "2.0")
 	params.Set("simplify", "true")
 
-	return c.httpRequest("", params, nil, responseData, true)
+	return c.withAccessToken(ctx, func(token string) error {
+		params.Set("session", token)
+		params.Set("timestamp", time.Now().Format("2006-01-02 15:04:05"))
+		return c.httpRequest(ctx, "", params, nil, responseData, true)
+	})
 }
 
-func (c *DingTalkClient) httpRequest(path string, params url.Values, requestData interface{}, responseData Unmarshallable, throughTaobao... bool) error {
-	client := c.HTTPClient
-	var request *http.Request
+func (c *DingTalkClient) httpRequest(ctx context.Context, path string, params url.Values, requestData interface{}, responseData Unmarshallable, throughTaobao ...bool) error {
+	if len(throughTaobao) > 0 && throughTaobao[0] {
+		body := &requestBody{
+			method:      "POST",
+			contentType: typeFormURLEncoded,
+			data:        []byte(params.Encode()),
+		}
+		return c.doRequest(ctx, params.Get("method"), params, body, responseData, true)
+	}
+	body, err := newRequestBody(requestData)
+	if err != nil {
+		return err
+	}
+	return c.doRequest(ctx, path, params, body, responseData)
+}
 
-	if len(throughTaobao) == 0 || !throughTaobao[0] {
-		url := BASE_URL + path + "?" + params.Encode()
-		if requestData != nil {
-			switch requestData.(type) {
-			case UploadFile:
-				var b bytes.Buffer
-				request, _ = http.NewRequest("POST", url, &b)
-				w := multipart.NewWriter(&b)
-
-				uploadFile := requestData.(UploadFile)
-				if uploadFile.Reader == nil {
-					return errors.New("upload file is empty")
-				}
-				fw, err := w.CreateFormFile(uploadFile.FieldName, uploadFile.FileName)
-				if err != nil {
-					return err
-				}
-				if _, err = io.Copy(fw, uploadFile.Reader); err != nil {
-					return err
-				}
-				if err = w.Close(); err != nil {
-					return err
-				}
-				request.Header.Set("Content-Type", w.FormDataContentType())
-			default:
-				d, _ := json.Marshal(requestData)
-				// log.Printf("url: %s request: %s", url, string(d))
-				request, _ = http.NewRequest("POST", url, bytes.NewReader(d))
-				request.Header.Set("Content-Type", typeJSON)
+//doRequest sends the request once c.RateLimiter allows it, retrying transient
+//failures according to c.RetryPolicy
+func (c *DingTalkClient) doRequest(ctx context.Context, path string, params url.Values, body *requestBody, responseData Unmarshallable, throughTaobao ...bool) error {
+	policy := c.RetryPolicy
+	for attempt := 1; ; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(ctx, path); err != nil {
+				return err
 			}
-		} else {
-			// log.Printf("url: %s", url)
-			request, _ = http.NewRequest("GET", url, nil)
 		}
-	} else {
-		buf := strings.NewReader(params.Encode())
-		request, _ = http.NewRequest("POST", TAOBAO_BASE_URL, buf)
-		request.Header.Set("Content-Type", typeFormURLEncoded)
-		fmt.Printf("req: %s\n", buf)
+		err := c.sendRequest(ctx, path, params, body, responseData, throughTaobao...)
+		if attempt >= policy.maxAttempts() || !policy.shouldRetry(ctx, path, err) {
+			return err
+		}
+		delay := policy.delay(attempt)
+		c.log(ctx, slog.LevelDebug, "retrying dingtalk request", "path", path, "attempt", attempt+1, "delay", delay)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
+
+//sendRequest makes a single attempt of the request
+func (c *DingTalkClient) sendRequest(ctx context.Context, path string, params url.Values, body *requestBody, responseData Unmarshallable, throughTaobao ...bool) (err error) {
+	start := time.Now()
+	status := 0
+	defer func() {
+		c.logRequest(ctx, body.method, path, params, status, time.Since(start), err)
+	}()
+
+	client := c.httpClient()
+	url := c.taobaoURL()
+	if len(throughTaobao) == 0 || !throughTaobao[0] {
+		url = c.baseURL() + path + "?" + params.Encode()
+	}
+	var reader io.Reader
+	if body.data != nil {
+		reader = bytes.NewReader(body.data)
+	}
+	request, err := http.NewRequestWithContext(ctx, body.method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body.contentType != "" {
+		request.Header.Set("Content-Type", body.contentType)
+	}
+	if c.UserAgent != "" {
+		request.Header.Set("User-Agent", c.UserAgent)
 	}
 
 	resp, err := client.Do(request)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
+	status = resp.StatusCode
+	return decodeResponse(resp, path, responseData)
+}
+
+//decodeResponse reads a DingTalk response into responseData and returns the error it reports
+func decodeResponse(resp *http.Response, path string, responseData Unmarshallable) error {
 	if resp.StatusCode != 200 {
-		return errors.New("Server error: " + resp.Status)
+		return &HTTPStatusError{
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
 	}
 
-	defer resp.Body.Close()
-
 	contentType := resp.Header.Get("Content-Type")
-	fmt.Printf("response content type: %s\n", contentType)
 	if strings.HasPrefix(contentType, typeJSON) || strings.HasPrefix(contentType, typeJS) {
 		content, err := ioutil.ReadAll(resp.Body)
-		fmt.Printf("response: %s\n", content)
-		if err == nil {
-			err = json.Unmarshal(content, responseData)
-			if err != nil {
-				return err
-			}
-			return responseData.checkError()
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(content, responseData)
+		if err != nil {
+			return err
 		}
-	} else {
-		buf := bytes.Buffer{}
-		// io.Copy(responseData.getWriter(), resp.Body)
-		io.Copy(&buf, resp.Body)
-		fmt.Printf("response: %s\n", buf.String())
-		return responseData.checkError()
-	}
-	return err
+		return withPath(responseData.checkError(), path)
+	}
+	buf := bytes.Buffer{}
+	// io.Copy(responseData.getWriter(), resp.Body)
+	io.Copy(&buf, resp.Body)
+	return withPath(responseData.checkError(), path)
+}
+
+func (c *DingTalkClient) logRequest(ctx context.Context, method string, path string, params url.Values, status int, duration time.Duration, err error) {
+	if c.Logger == nil {
+		return
+	}
+	args := []interface{}{
+		"method", method,
+		"path", path,
+		"params", redactParams(params),
+		"status", status,
+		"duration", duration,
+	}
+	var apiErr *APIError
+	var taobaoErr *TaobaoAPIError
+	switch {
+	case errors.As(err, &apiErr):
+		args = append(args, "errcode", apiErr.ErrCode, "request_id", apiErr.RequestID)
+	case errors.As(err, &taobaoErr):
+		args = append(args, "errcode", taobaoErr.Code, "sub_code", taobaoErr.SubCode, "request_id", taobaoErr.RequestID)
+	case err == nil:
+		args = append(args, "errcode", 0)
+	}
+	if err != nil {
+		c.log(ctx, slog.LevelWarn, "dingtalk request failed", append(args, "error", err.Error())...)
+		return
+	}
+	c.log(ctx, slog.LevelDebug, "dingtalk request", args...)
 }
@@ -0,0 +1,112 @@
+package godingtalk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestContextCancelAbortsRequest(t *testing.T) {
+	started := make(chan struct{})
+	client := &DingTalkClient{
+		AccessToken: "token",
+		RWMutex:     &sync.RWMutex{},
+		HTTPClient: &http.Client{
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				close(started)
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.UserListContext(ctx, 1)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not aborted by context cancellation")
+	}
+}
+
+func TestAccessTokenFetchedOnDemand(t *testing.T) {
+	var paths []string
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/gettoken":
+			return jsonResponse(200, `{"errcode":0,"access_token":"fresh","expires_in":7200}`), nil
+		default:
+			if token := r.URL.Query().Get("access_token"); token != "fresh" {
+				t.Errorf("unexpected access_token %q", token)
+			}
+			return jsonResponse(200, `{"errcode":0}`), nil
+		}
+	})
+	client.AccessToken = ""
+	client.CorpSecret = "secret"
+	client.Cache = NewInMemoryCache()
+
+	if _, err := client.DepartmentList(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DepartmentList(); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || paths[0] != "/gettoken" {
+		t.Errorf("expected one gettoken call followed by two API calls, got %v", paths)
+	}
+}
+
+func TestExpiredTokenRefreshedAndUploadReplayed(t *testing.T) {
+	var bodies []string
+	client := newStubClient(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/gettoken" {
+			return jsonResponse(200, `{"errcode":0,"access_token":"fresh","expires_in":7200}`), nil
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if r.URL.Query().Get("access_token") != "fresh" {
+			return jsonResponse(200, `{"errcode":42001,"errmsg":"access_token expired"}`), nil
+		}
+		return jsonResponse(200, `{"errcode":0,"media_id":"@media"}`), nil
+	})
+	client.AccessToken = "stale"
+	client.CorpSecret = "secret"
+	client.Cache = NewInMemoryCache()
+
+	media, err := client.UploadMedia("file", "hello.txt", strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.MediaID != "@media" {
+		t.Errorf("unexpected media id %q", media.MediaID)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] || !strings.Contains(bodies[1], "hello world") {
+		t.Errorf("upload body was not replayed: %q", bodies)
+	}
+}
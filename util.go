@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"time"
+	"net/url"
 	"os"
 	"path"
+	"sync"
+	"time"
 )
 
 type Expirable interface {
@@ -16,11 +18,28 @@ type Expirable interface {
 	ExpiresIn() int
 }
 
+//Cache stores expirable data such as access tokens and tickets under a key.
+//A single cache can be shared by clients of different corps and apps
 type Cache interface {
-	Set(data Expirable) error
-	Get(data Expirable) error
+	Set(key string, data Expirable) error
+	Get(key string, data Expirable) error
+}
+
+//ErrCacheMiss is returned by Cache.Get when nothing is stored under the key
+var ErrCacheMiss = errors.New("Data is not cached")
+
+//ErrCacheExpired is returned by Cache.Get when the stored data is expired
+var ErrCacheExpired = errors.New("Data is already expired")
+
+//checkExpired treats data as expired one minute before it really expires
+func checkExpired(data Expirable) error {
+	if time.Now().Unix() > data.CreatedAt()+int64(data.ExpiresIn()-60) {
+		return ErrCacheExpired
+	}
+	return nil
 }
 
+//FileCache stores each key in its own file next to Path
 type FileCache struct {
 	Path string
 }
@@ -31,53 +50,83 @@ func NewFileCache(filename string) *FileCache {
 	}
 }
 
-func (c *FileCache) Set(data Expirable) error {
+func (c *FileCache) file(key string) string {
+	return c.Path + "." + url.QueryEscape(key)
+}
+
+//Set writes to a temporary file first, so that concurrent readers never see a partial entry
+func (c *FileCache) Set(key string, data Expirable) error {
 	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.file(key), bytes)
+}
+
+//writeFileAtomic replaces file with data through a temporary file and a rename
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := ioutil.TempFile(path.Dir(file), path.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err == nil {
-		ioutil.WriteFile(c.Path, bytes, 0644)
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
 	}
 	return err
 }
 
-func (c *FileCache) Get(data Expirable) error {
-	bytes, err := ioutil.ReadFile(c.Path)
+func (c *FileCache) Get(key string, data Expirable) error {
+	bytes, err := ioutil.ReadFile(c.file(key))
+	if os.IsNotExist(err) {
+		return ErrCacheMiss
+	}
 	if err == nil {
 		err = json.Unmarshal(bytes, data)
 		if err == nil {
-			created := data.CreatedAt()
-			expires := data.ExpiresIn()
-			if err == nil && time.Now().Unix() > created+int64(expires-60) {
-				err = errors.New("Data is already expired")
-			}
+			err = checkExpired(data)
 		}
 	}
 	return err
 }
 
 type InMemoryCache struct {
-	data []byte
+	mu   sync.RWMutex
+	data map[string][]byte
 }
 
 func NewInMemoryCache() *InMemoryCache {
-	return &InMemoryCache{}
+	return &InMemoryCache{
+		data: map[string][]byte{},
+	}
 }
 
-func (c *InMemoryCache) Set(data Expirable) error {
+func (c *InMemoryCache) Set(key string, data Expirable) error {
 	bytes, err := json.Marshal(data)
 	if err == nil {
-		c.data = bytes
+		c.mu.Lock()
+		c.data[key] = bytes
+		c.mu.Unlock()
 	}
 	return err
 }
 
-func (c *InMemoryCache) Get(data Expirable) error {
-	err := json.Unmarshal(c.data, data)
+func (c *InMemoryCache) Get(key string, data Expirable) error {
+	c.mu.RLock()
+	bytes, ok := c.data[key]
+	c.mu.RUnlock()
+	if !ok {
+		return ErrCacheMiss
+	}
+	err := json.Unmarshal(bytes, data)
 	if err == nil {
-		created := data.CreatedAt()
-		expires := data.ExpiresIn()
-		if err == nil && time.Now().Unix() > created+int64(expires-60) {
-			err = errors.New("Data is already expired")
-		}
+		err = checkExpired(data)
 	}
 	return err
 }
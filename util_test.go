@@ -24,10 +24,10 @@ func TestFileCache(t *testing.T) {
 		Expires: 7200,
 		Created: time.Now().Unix(),
 	}
-	cache.Set(&data)
+	cache.Set("test", &data)
 
 	var data2 ExpiresData
-	cache.Get(&data2)
+	cache.Get("test", &data2)
 	t.Logf("%+v %+v", data, data2)
 
 	if data2.Created != data.Created {
@@ -39,8 +39,8 @@ func TestFileCache(t *testing.T) {
 		Expires: 0,
 		Created: time.Now().Unix(),
 	}
-	cache.Set(&data)
-	err := cache.Get(&data2)
+	cache.Set("test", &data)
+	err := cache.Get("test", &data2)
 	if err == nil {
 		t.Error("FileCache error: err should not be nil")
 	}
@@ -54,10 +54,10 @@ func TestInMemoryCache(t *testing.T) {
 		Expires: 7200,
 		Created: time.Now().Unix(),
 	}
-	cache.Set(&data)
+	cache.Set("test", &data)
 
 	var data2 ExpiresData
-	cache.Get(&data2)
+	cache.Get("test", &data2)
 	t.Logf("%+v %+v", data, data2)
 
 	if data2.Created != data.Created {
@@ -69,10 +69,27 @@ func TestInMemoryCache(t *testing.T) {
 		Expires: 0,
 		Created: time.Now().Unix(),
 	}
-	cache.Set(&data)
-	err := cache.Get(&data2)
+	cache.Set("test", &data)
+	err := cache.Get("test", &data2)
 	if err == nil {
 		t.Error("InMemoryCache error: err should not be nil")
 	}
 	t.Logf("%+v %+v", data, data2)
 }
+
+func TestCacheKeysAreIsolated(t *testing.T) {
+	for _, cache := range []Cache{NewFileCache(".test_cache"), NewInMemoryCache()} {
+		a := ExpiresData{Data: "corp a", Expires: 7200, Created: time.Now().Unix()}
+		b := ExpiresData{Data: "corp b", Expires: 7200, Created: time.Now().Unix()}
+		cache.Set("access_token:a", &a)
+		cache.Set("access_token:b", &b)
+
+		var got ExpiresData
+		if err := cache.Get("access_token:a", &got); err != nil || got.Data != "corp a" {
+			t.Errorf("%T: expected corp a, got %+v %v", cache, got, err)
+		}
+		if err := cache.Get("access_token:missing", &got); err != ErrCacheMiss {
+			t.Errorf("%T: expected ErrCacheMiss, got %v", cache, err)
+		}
+	}
+}